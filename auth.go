@@ -0,0 +1,433 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDigestChallenge is returned for a digest challenge that cannot be answered
+var ErrDigestChallenge = errors.New("request: unsupported digest challenge")
+
+// HMAC signature headers
+const (
+	HMACDateHeader = "X-Date"
+	HMACBodyHeader = "X-Content-Hash"
+)
+
+// Authenticator authorizes the requests
+// see Option.Auth and #SetAuth
+type Authenticator interface {
+	// Authenticate is called before every attempt, after the before hooks
+	Authenticate(req *http.Request) error
+}
+
+// Challenger is an #Authenticator that answers 401 challenges
+type Challenger interface {
+	Authenticator
+
+	// Challenge is called with a 401 response to req
+	// req is authenticated and sent once more if it returns true
+	Challenge(req *http.Request, res *http.Response) (retry bool, err error)
+}
+
+type authKey struct{}
+
+// SetAuth sets client default authenticator, Option.Auth overrides it
+func (c *Client) SetAuth(auth Authenticator) {
+	debug()
+
+	c.auth = auth
+}
+
+// withAuth returns ctx with the authenticator of the request
+func (c *Client) withAuth(ctx context.Context, opt *Option) context.Context {
+	auth := c.auth
+	if opt.Auth != nil {
+		auth = opt.Auth
+	}
+
+	// even nil, not to inherit the one of an outer request, like a token request
+	return context.WithValue(ctx, authKey{}, auth)
+}
+
+// authenticator returns the authenticator of req if any
+func authenticator(req *http.Request) Authenticator {
+	auth, _ := req.Context().Value(authKey{}).(Authenticator)
+	return auth
+}
+
+// challenge answers a 401 res to req if its authenticator can
+// it returns the request to send again, nil if none
+func challenge(req *http.Request, res *http.Response) (retryReq *http.Request, err error) {
+	challenger, ok := authenticator(req).(Challenger)
+	if !ok || res.StatusCode != http.StatusUnauthorized || !replayable(req) {
+		return
+	}
+
+	retry, err := challenger.Challenge(req, res)
+	if err != nil || !retry {
+		return
+	}
+
+	debug("CHALLENGE", req.URL)
+
+	return replay(req, req.Context())
+}
+
+// bodyBytes returns the body of req without consuming it
+// a body without GetBody is read in memory and made replayable
+func bodyBytes(req *http.Request) (data []byte, err error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+
+		return ioutil.ReadAll(body)
+	}
+
+	data, err = ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return
+	}
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.Body, _ = req.GetBody()
+
+	return
+}
+
+// BasicAuth is the http basic #Authenticator
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Authenticate implements #Authenticator
+func (a *BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// BearerAuth is a static bearer token #Authenticator
+type BearerAuth struct {
+	Token string
+}
+
+// Authenticate implements #Authenticator
+func (a *BearerAuth) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// DigestAuth is the http digest #Challenger, RFC 7616
+// the first request gets the 401 challenge, the next ones reuse it with a new nonce count
+type DigestAuth struct {
+	Username string
+	Password string
+
+	mu        sync.Mutex
+	challenge *digestChallenge
+	count     int
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string // "auth", "auth-int" or "" for RFC 2069
+}
+
+// Authenticate implements #Authenticator
+func (a *DigestAuth) Authenticate(req *http.Request) (err error) {
+	a.mu.Lock()
+	challenge := a.challenge
+	a.count++
+	count := a.count
+	a.mu.Unlock()
+
+	// no challenge yet
+	if challenge == nil {
+		return
+	}
+
+	var body []byte
+	if challenge.qop == "auth-int" {
+		body, err = bodyBytes(req)
+		if err != nil {
+			return
+		}
+	}
+
+	header, err := challenge.authorization(a.Username, a.Password, req.Method, req.URL.RequestURI(), body, count)
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("Authorization", header)
+	return
+}
+
+// Challenge implements #Challenger
+func (a *DigestAuth) Challenge(req *http.Request, res *http.Response) (retry bool, err error) {
+	params, ok := parseDigestChallenge(res.Header.Get("WWW-Authenticate"))
+	if !ok {
+		return
+	}
+
+	challenge := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: params["algorithm"],
+	}
+
+	// auth is preferred over auth-int
+	for _, qop := range strings.Split(params["qop"], ",") {
+		qop = strings.TrimSpace(qop)
+
+		if qop == "auth" || (qop == "auth-int" && challenge.qop == "") {
+			challenge.qop = qop
+		}
+	}
+
+	if challenge.hash() == nil {
+		return false, fmt.Errorf("%w: algorithm %s", ErrDigestChallenge, challenge.algorithm)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// the credentials are wrong unless the nonce is stale
+	answered := req.Header.Get("Authorization") != ""
+	if answered && !strings.EqualFold(params["stale"], "true") {
+		return
+	}
+
+	a.challenge = challenge
+	a.count = 0
+
+	return true, nil
+}
+
+// parseDigestChallenge returns the params of a Digest WWW-Authenticate header
+func parseDigestChallenge(header string) (params map[string]string, ok bool) {
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Digest ") {
+		return
+	}
+
+	params = map[string]string{}
+
+	rest := header[7:]
+
+	for {
+		rest = strings.TrimLeft(rest, " ,")
+		if rest == "" {
+			break
+		}
+
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			break
+		}
+
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimLeft(rest[eq+1:], " ")
+
+		var value string
+
+		if strings.HasPrefix(rest, `"`) {
+			var b strings.Builder
+
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				// quoted-pair
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+
+				b.WriteByte(rest[i])
+			}
+
+			value = b.String()
+
+			// unterminated
+			if i >= len(rest) {
+				i = len(rest) - 1
+			}
+
+			rest = rest[i+1:]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+
+			value = strings.TrimSpace(rest[:end])
+			rest = rest[end:]
+		}
+
+		params[key] = value
+	}
+
+	return params, params["nonce"] != ""
+}
+
+// hash returns the hash func of the challenge algorithm, nil if unsupported
+func (c *digestChallenge) hash() func() hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+
+	return nil
+}
+
+// authorization returns the Authorization header answering the challenge
+func (c *digestChallenge) authorization(username, password, method, uri string, body []byte, count int) (header string, err error) {
+	newHash := c.hash()
+
+	h := func(s string) string {
+		hash := newHash()
+		hash.Write([]byte(s))
+		return hex.EncodeToString(hash.Sum(nil))
+	}
+
+	cnonce, err := randomHex(16)
+	if err != nil {
+		return
+	}
+
+	nc := fmt.Sprintf("%08x", count)
+
+	ha1 := h(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+
+	ha2 := h(method + ":" + uri)
+	if c.qop == "auth-int" {
+		bodyHash := newHash()
+		bodyHash.Write(body)
+		ha2 = h(method + ":" + uri + ":" + hex.EncodeToString(bodyHash.Sum(nil)))
+	}
+
+	var response string
+	if c.qop == "" {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + nc + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	}
+
+	fields := []string{
+		fmt.Sprintf(`username="%s"`, escapeQuotes(username)),
+		fmt.Sprintf(`realm="%s"`, escapeQuotes(c.realm)),
+		fmt.Sprintf(`nonce="%s"`, escapeQuotes(c.nonce)),
+		fmt.Sprintf(`uri="%s"`, escapeQuotes(uri)),
+	}
+
+	if c.algorithm != "" {
+		fields = append(fields, "algorithm="+c.algorithm)
+	}
+
+	if c.qop != "" {
+		fields = append(fields, "qop="+c.qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+
+	fields = append(fields, fmt.Sprintf(`response="%s"`, response))
+
+	if c.opaque != "" {
+		fields = append(fields, fmt.Sprintf(`opaque="%s"`, escapeQuotes(c.opaque)))
+	}
+
+	header = "Digest " + strings.Join(fields, ", ")
+	return
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// HMACAuth is an #Authenticator that signs the requests with a shared secret
+// it sets the HMACDateHeader and HMACBodyHeader (hex hash of the body) headers and
+//
+//	Authorization: HMAC keyId="KeyID",headers="x-date x-content-hash ...",signature="base64"
+//
+// the signature is the HMAC of the lines
+// method, request uri, then "name:value" of every signed header in order
+type HMACAuth struct {
+	KeyID   string
+	Secret  []byte
+	Headers []string         // more signed headers, like "Content-Type"
+	Hash    func() hash.Hash // default: sha256.New
+}
+
+// Authenticate implements #Authenticator
+func (a *HMACAuth) Authenticate(req *http.Request) (err error) {
+	newHash := a.Hash
+	if newHash == nil {
+		newHash = sha256.New
+	}
+
+	body, err := bodyBytes(req)
+	if err != nil {
+		return
+	}
+
+	bodyHash := newHash()
+	bodyHash.Write(body)
+
+	req.Header.Set(HMACDateHeader, time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set(HMACBodyHeader, hex.EncodeToString(bodyHash.Sum(nil)))
+
+	headers := append([]string{HMACDateHeader, HMACBodyHeader}, a.Headers...)
+
+	lines := []string{req.Method, req.URL.RequestURI()}
+	names := make([]string, len(headers))
+
+	for i, header := range headers {
+		names[i] = strings.ToLower(header)
+		lines = append(lines, names[i]+":"+req.Header.Get(header))
+	}
+
+	mac := hmac.New(newHash, a.Secret)
+	mac.Write([]byte(strings.Join(lines, "\n")))
+
+	req.Header.Set("Authorization", fmt.Sprintf(`HMAC keyId="%s",headers="%s",signature="%s"`,
+		escapeQuotes(a.KeyID), strings.Join(names, " "), base64.StdEncoding.EncodeToString(mac.Sum(nil))))
+
+	return
+}
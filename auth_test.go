@@ -0,0 +1,321 @@
+package request
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// digestServer is a local server behind http digest auth of user:pass
+type digestServer struct {
+	*httptest.Server
+
+	algorithm string
+	qop       string
+
+	mu         sync.Mutex
+	challenges int
+	counts     []string
+}
+
+func newDigestServer(algorithm, qop string) *digestServer {
+	s := &digestServer{algorithm: algorithm, qop: qop}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	return s
+}
+
+func (s *digestServer) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	params, ok := parseDigestChallenge(r.Header.Get("Authorization"))
+	if !ok || params["response"] != s.response(r, body, params) {
+		s.mu.Lock()
+		s.challenges++
+		s.mu.Unlock()
+
+		challenge := fmt.Sprintf(`Digest realm="test", nonce="abc", opaque="xyz", qop="%s"`, s.qop)
+		if s.algorithm != "" {
+			challenge += ", algorithm=" + s.algorithm
+		}
+
+		w.Header().Set("WWW-Authenticate", challenge)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	s.counts = append(s.counts, params["nc"])
+	s.mu.Unlock()
+
+	w.Write(body)
+}
+
+func (s *digestServer) response(r *http.Request, body []byte, params map[string]string) string {
+	newHash := md5.New
+	if s.algorithm == "SHA-256" {
+		newHash = sha256.New
+	}
+
+	h := func(data string) string {
+		return hexHash(newHash, []byte(data))
+	}
+
+	ha1 := h("user:test:pass")
+	ha2 := h(r.Method + ":" + params["uri"])
+
+	if params["qop"] == "auth-int" {
+		ha2 = h(r.Method + ":" + params["uri"] + ":" + hexHash(newHash, body))
+	}
+
+	if params["uri"] != r.URL.RequestURI() || params["opaque"] != "xyz" || params["qop"] != s.qop {
+		return ""
+	}
+
+	return h(ha1 + ":abc:" + params["nc"] + ":" + params["cnonce"] + ":" + params["qop"] + ":" + ha2)
+}
+
+func hexHash(newHash func() hash.Hash, data []byte) string {
+	h := newHash()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestBasicAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		w.Write([]byte(username + ":" + password))
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.SetAuth(&BasicAuth{Username: "user", Password: "pass"})
+
+	data, _, err := client.Request(&Option{
+		URL: ts.URL,
+		Header: &Header{
+			"Authorization": "Bearer wins",
+		},
+	})
+	if err != nil || string(data) != "user:pass" {
+		t.Error(err, string(data))
+		return
+	}
+
+	// the option wins
+	data, _, _ = client.Request(&Option{
+		URL:  ts.URL,
+		Auth: &BasicAuth{Username: "other"},
+	})
+	if string(data) != "other:" {
+		t.Error(string(data))
+		return
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer ts.Close()
+
+	data, _, err := New().Request(&Option{
+		URL:  ts.URL,
+		Auth: &BearerAuth{Token: "abc"},
+	})
+	if err != nil || string(data) != "Bearer abc" {
+		t.Error(err, string(data))
+		return
+	}
+}
+
+func TestDigestAuth(t *testing.T) {
+	ts := newDigestServer("", "auth")
+	defer ts.Close()
+
+	client := New()
+	client.SetAuth(&DigestAuth{Username: "user", Password: "pass"})
+
+	for i := 0; i < 3; i++ {
+		data, res, err := client.Request(&Option{
+			URL:     ts.URL + "/dir/index.html?one=1",
+			Method:  "POST",
+			BodyStr: "hello",
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if res.StatusCode != 200 || string(data) != "hello" {
+			t.Error(res.StatusCode, string(data))
+			return
+		}
+	}
+
+	// one challenge, then the nonce count goes on
+	if ts.challenges != 1 || strings.Join(ts.counts, ",") != "00000001,00000002,00000003" {
+		t.Error(ts.challenges, ts.counts)
+		return
+	}
+}
+
+func TestDigestAuthInt(t *testing.T) {
+	ts := newDigestServer("SHA-256", "auth-int")
+	defer ts.Close()
+
+	client := New()
+
+	data, res, err := client.Request(&Option{
+		URL:    ts.URL,
+		Method: "PUT",
+		JSON: map[string]string{
+			"one": "1",
+		},
+		Auth: &DigestAuth{Username: "user", Password: "pass"},
+	})
+	if err != nil || res.StatusCode != 200 || string(data) != `{"one":"1"}` {
+		t.Error(err, string(data))
+		return
+	}
+}
+
+func TestDigestAuthWrong(t *testing.T) {
+	ts := newDigestServer("MD5", "auth")
+	defer ts.Close()
+
+	_, res, err := New().Request(&Option{
+		URL:  ts.URL,
+		Auth: &DigestAuth{Username: "user", Password: "wrong"},
+	})
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		t.Error(err)
+		return
+	}
+
+	// no loop
+	if ts.challenges != 2 {
+		t.Error(ts.challenges)
+		return
+	}
+
+	ts.algorithm = "SHA-512-256"
+
+	_, _, err = New().Request(&Option{
+		URL:  ts.URL,
+		Auth: &DigestAuth{Username: "user", Password: "pass"},
+	})
+	if !errors.Is(err, ErrDigestChallenge) {
+		t.Error(err)
+		return
+	}
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	params, ok := parseDigestChallenge(`Digest realm="a \"b\", c", qop="auth,auth-int", nonce="n", algorithm=MD5, stale=TRUE`)
+	if !ok {
+		t.Error()
+		return
+	}
+
+	expected := map[string]string{
+		"realm":     `a "b", c`,
+		"qop":       "auth,auth-int",
+		"nonce":     "n",
+		"algorithm": "MD5",
+		"stale":     "TRUE",
+	}
+
+	for key, value := range expected {
+		if params[key] != value {
+			t.Error(key, params[key])
+			return
+		}
+	}
+
+	_, ok = parseDigestChallenge(`Basic realm="test"`)
+	if ok {
+		t.Error()
+		return
+	}
+
+	params, _ = parseDigestChallenge(`Digest nonce="unterminated`)
+	if params["nonce"] != "unterminated" {
+		t.Error(params)
+		return
+	}
+}
+
+func TestHMACAuth(t *testing.T) {
+	secret := []byte("secret")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		if r.Header.Get(HMACBodyHeader) != hexHash(sha256.New, body) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		lines := []string{
+			r.Method,
+			r.URL.RequestURI(),
+			"x-date:" + r.Header.Get("X-Date"),
+			"x-content-hash:" + r.Header.Get("X-Content-Hash"),
+			"content-type:" + r.Header.Get("Content-Type"),
+		}
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(strings.Join(lines, "\n")))
+
+		expected := `HMAC keyId="app",headers="x-date x-content-hash content-type",signature="` +
+			base64.StdEncoding.EncodeToString(mac.Sum(nil)) + `"`
+
+		if r.Header.Get("Authorization") != expected {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.SetAuth(&HMACAuth{
+		KeyID:   "app",
+		Secret:  secret,
+		Headers: []string{"Content-Type"},
+	})
+
+	data, res, err := client.Request(&Option{
+		URL:    ts.URL + "/sign?one=1",
+		Method: "POST",
+		Form: &Data{
+			"two": []string{"2"},
+		},
+	})
+	if err != nil || res.StatusCode != 200 || string(data) != "two=2" {
+		t.Error(err, res.StatusCode, string(data))
+		return
+	}
+
+	// no body
+	_, res, _ = client.Request(&Option{
+		URL: ts.URL,
+	})
+	if res.StatusCode != 200 {
+		t.Error(res.StatusCode)
+		return
+	}
+}
@@ -0,0 +1,109 @@
+package request
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// DefaultCompressMinSize is the smallest request body compressed by default
+	DefaultCompressMinSize = 1024
+)
+
+// ErrCompressEncoding is returned for a #CompressOption encoding other than gzip or zstd
+var ErrCompressEncoding = errors.New("request: unsupported request body encoding")
+
+// CompressOption holds the request body compression
+// the bodies of Files are streamed and never compressed
+type CompressOption struct {
+	Encoding string // ContentGzip or ContentZstd, default: ContentGzip
+	MinSize  int64  // smaller bodies are sent as is, default: DefaultCompressMinSize, negative turns it off
+}
+
+// SetCompress sets client request body compression, Option.Compress overrides it
+// nil turns it off
+func (c *Client) SetCompress(opt *CompressOption) {
+	debug(opt)
+
+	c.compress = opt
+}
+
+// compressBody compresses the body of req built from opt
+// it is done once, before the hooks and the authenticator, so the retries and signatures see the compressed body
+func (c *Client) compressBody(req *http.Request, opt *Option) (err error) {
+	compress := c.compress
+	if opt.Compress != nil {
+		compress = opt.Compress
+	}
+
+	if compress == nil || opt.Files != nil || req.GetBody == nil || req.Header.Get("Content-Encoding") != "" {
+		return
+	}
+
+	minSize := compress.MinSize
+	if minSize == 0 {
+		minSize = DefaultCompressMinSize
+	}
+
+	if minSize < 0 || req.ContentLength < minSize {
+		return
+	}
+
+	encoding := compress.Encoding
+	if encoding == "" {
+		encoding = ContentGzip
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return
+	}
+	defer body.Close()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+
+	switch encoding {
+	case ContentGzip:
+		w = gzip.NewWriter(&buf)
+
+	case ContentZstd:
+		w, err = zstd.NewWriter(&buf, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return
+		}
+
+	default:
+		return fmt.Errorf("%w: %s", ErrCompressEncoding, encoding)
+	}
+
+	_, err = io.Copy(w, body)
+	if err != nil {
+		return
+	}
+
+	err = w.Close()
+	if err != nil {
+		return
+	}
+
+	data := buf.Bytes()
+
+	debug("COMPRESS", encoding, req.ContentLength, len(data))
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.Body, _ = req.GetBody()
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Encoding", encoding)
+
+	return
+}
@@ -0,0 +1,189 @@
+package request
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// newCompressServer decodes the request body and echoes it
+// with its Content-Encoding in X-Encoding and its sizes on the wire and decoded in X-Size
+func newCompressServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := ioutil.ReadAll(r.Body)
+		body := raw
+
+		switch r.Header.Get("Content-Encoding") {
+		case ContentGzip:
+			gz, _ := gzip.NewReader(bytes.NewReader(raw))
+			body, _ = ioutil.ReadAll(gz)
+
+		case ContentZstd:
+			zr, _ := zstd.NewReader(bytes.NewReader(raw))
+			body, _ = ioutil.ReadAll(zr)
+			zr.Close()
+		}
+
+		w.Header().Set("X-Encoding", r.Header.Get("Content-Encoding"))
+		w.Header().Set("X-Size", fmt.Sprint(len(raw), "/", len(body)))
+		w.Write(body)
+	}))
+}
+
+func TestCompress(t *testing.T) {
+	ts := newCompressServer()
+	defer ts.Close()
+
+	large := map[string]string{
+		"data": strings.Repeat("compress me ", 200),
+	}
+
+	client := New()
+	client.SetCompress(&CompressOption{})
+
+	res, err := client.Do(&Option{
+		URL:    ts.URL,
+		Method: "POST",
+		JSON:   large,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var wire, decoded int
+	fmt.Sscanf(res.HeaderValue("X-Size"), "%d/%d", &wire, &decoded)
+
+	if res.HeaderValue("X-Encoding") != ContentGzip || wire >= decoded/10 {
+		t.Error(res.Header())
+		return
+	}
+
+	var echoed map[string]string
+	if res.JSON(&echoed) != nil || echoed["data"] != large["data"] {
+		t.Error(res.String())
+		return
+	}
+
+	// small
+	res, _ = client.Do(&Option{
+		URL:     ts.URL,
+		Method:  "POST",
+		BodyStr: "small",
+	})
+	if res.HeaderValue("X-Encoding") != "" || res.String() != "small" {
+		t.Error(res.Header())
+		return
+	}
+
+	// the option wins
+	res, _ = client.Do(&Option{
+		URL:      ts.URL,
+		Method:   "POST",
+		BodyStr:  "small",
+		Compress: &CompressOption{Encoding: ContentZstd, MinSize: 1},
+	})
+	if res.HeaderValue("X-Encoding") != ContentZstd || res.String() != "small" {
+		t.Error(res.Header())
+		return
+	}
+
+	res, _ = client.Do(&Option{
+		URL:      ts.URL,
+		Method:   "POST",
+		JSON:     large,
+		Compress: &CompressOption{MinSize: -1},
+	})
+	if res.HeaderValue("X-Encoding") != "" {
+		t.Error(res.Header())
+		return
+	}
+
+	_, err = client.Do(&Option{
+		URL:      ts.URL,
+		Method:   "POST",
+		JSON:     large,
+		Compress: &CompressOption{Encoding: ContentBrotli},
+	})
+	if !errors.Is(err, ErrCompressEncoding) {
+		t.Error(err)
+		return
+	}
+}
+
+func TestCompressRetry(t *testing.T) {
+	ts, counter := newFlakyServer(1, http.StatusBadGateway, nil)
+	defer ts.Close()
+
+	body := strings.Repeat("retry me ", 200)
+
+	client := New()
+	client.SetRetry(newTestRetryPolicy())
+	client.SetCompress(&CompressOption{MinSize: 100})
+
+	// the hooks see the compressed body
+	var hooked []string
+
+	client.BeforeRequest(func(req *http.Request) error {
+		hooked = append(hooked, req.Header.Get("Content-Encoding"))
+		return nil
+	})
+
+	data, _, err := client.Request(&Option{
+		URL:     ts.URL,
+		Method:  "PUT",
+		BodyStr: body,
+	})
+	if err != nil || *counter != 2 || strings.Join(hooked, ",") != "gzip,gzip" {
+		t.Error(err, *counter, hooked)
+		return
+	}
+
+	// echoed as sent
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	decoded, _ := ioutil.ReadAll(gz)
+	if string(decoded) != body {
+		t.Error(string(decoded))
+		return
+	}
+}
+
+func TestCompressSigned(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := ioutil.ReadAll(r.Body)
+
+		// the signature covers the bytes on the wire
+		if r.Header.Get(HMACBodyHeader) != hexHash(sha256.New, raw) || r.Header.Get("Content-Encoding") != ContentGzip {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.SetCompress(&CompressOption{MinSize: 10})
+	client.SetAuth(&HMACAuth{KeyID: "app", Secret: []byte("secret")})
+
+	_, res, err := client.Request(&Option{
+		URL:     ts.URL,
+		Method:  "POST",
+		BodyStr: strings.Repeat("sign me ", 10),
+	})
+	if err != nil || res.StatusCode != 200 {
+		t.Error(err, res.StatusCode)
+		return
+	}
+}
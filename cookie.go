@@ -3,9 +3,13 @@ package request
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -64,6 +68,91 @@ func (c *Client) GetCookie(domain, name string) (value string, err error) {
 	return
 }
 
+// ErrJar is returned when the client jar is not a #Jar
+var ErrJar = errors.New("request: the cookie jar can not be managed")
+
+// jar returns the client #Jar
+func (c *Client) jar() (jar *Jar, err error) {
+	jar, ok := c.httpClient.Jar.(*Jar)
+	if !ok {
+		err = ErrJar
+	}
+
+	return
+}
+
+// ListDomains lists the domains the client has cookies for
+func (c *Client) ListDomains() (domains []string, err error) {
+	jar, err := c.jar()
+	if err != nil {
+		return
+	}
+
+	domains = jar.Domains()
+	return
+}
+
+// AllCookies gets all the client cookies with all their attributes
+func (c *Client) AllCookies() (cookies []*Cookie, err error) {
+	jar, err := c.jar()
+	if err != nil {
+		return
+	}
+
+	cookies = jar.All()
+	return
+}
+
+// DeleteCookie deletes the cookies named name of domain
+// domain is a host like "httpbin.org" or an url like GetCookies
+func (c *Client) DeleteCookie(domain, name string) (err error) {
+	debug(domain, name)
+
+	jar, err := c.jar()
+	if err != nil {
+		return
+	}
+
+	jar.Delete(cookieDomain(domain), name)
+	return
+}
+
+// ClearDomain deletes the cookies of domain and its subdomains
+// domain is a host like "httpbin.org" or an url like GetCookies
+func (c *Client) ClearDomain(domain string) (err error) {
+	debug(domain)
+
+	jar, err := c.jar()
+	if err != nil {
+		return
+	}
+
+	jar.ClearDomain(cookieDomain(domain))
+	return
+}
+
+// ClearAll deletes all the client cookies
+func (c *Client) ClearAll() (err error) {
+	debug()
+
+	jar, err := c.jar()
+	if err != nil {
+		return
+	}
+
+	jar.Clear()
+	return
+}
+
+// cookieDomain returns the lowercase host of domain
+func cookieDomain(domain string) string {
+	if u, err := url.Parse(domain); err == nil && u.Host != "" {
+		domain = u.Hostname()
+	}
+
+	return strings.ToLower(strings.TrimPrefix(domain, "."))
+}
+
 // cookie is a duplicate of http.Cookie but with json parser
 type cookie struct {
 	Name  string `json:"name"`
@@ -72,31 +161,85 @@ type cookie struct {
 	Path       string    `json:"path"`
 	Domain     string    `json:"domain"`
 	Expires    time.Time `json:"-"`
-	RawExpires string    `json:"expires,omitempty"` // for reading cookies only
+	RawExpires string    `json:"expires,omitempty"` // any of cookieTimeLayouts or unix time
+	Expiry     int64     `json:"expiry,omitempty"`  // unix time, wins over RawExpires
+
+	MaxAge   int    `json:"maxage,omitempty"` // the jar keeps max-age as expires
+	Secure   bool   `json:"secure"`
+	HttpOnly bool   `json:"httponly"`
+	HostOnly bool   `json:"hostonly,omitempty"` // not sent to subdomains of Domain
+	SameSite string `json:"samesite,omitempty"` // "lax", "strict" or "none"
+}
+
+// cookieTimeLayouts are the accepted layouts of cookie.RawExpires
+var cookieTimeLayouts = []string{
+	time.RFC1123,
+	time.RFC1123Z,
+	"Mon, 02-Jan-2006 15:04:05 MST", // netscape
+	time.RFC850,
+	time.ANSIC,
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+}
+
+// parseCookieTime parses value as any of cookieTimeLayouts or as unix time in second
+func parseCookieTime(value string) (t time.Time, ok bool) {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range cookieTimeLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, true
+		}
+	}
 
-	// Expiry int64 `json:"expiry"`
+	sec, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return
+	}
+
+	return unixFloat(sec), true
+}
+
+// unixFloat returns the time of sec unix time in second with fraction
+func unixFloat(sec float64) time.Time {
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
+
+// setExpires sets Expires, RawExpires and Expiry from t
+func (c *cookie) setExpires(t time.Time) {
+	c.Expires = t
+	c.RawExpires = ""
+	c.Expiry = 0
+
+	if t.IsZero() {
+		return
+	}
 
-	MaxAge   int  `json:"maxage,omitempty"`
-	Secure   bool `json:"secure"`
-	HttpOnly bool `json:"httponly"`
+	c.RawExpires = t.UTC().Format(http.TimeFormat)
+	c.Expiry = t.Unix()
 }
 
 // debug purpose
 func (c cookie) String() string {
-	return fmt.Sprintf("\nName\t\t:%s\nValue\t\t:%s\nPath\t\t:%s\nDomain\t\t:%s\nExpires\t\t:%v\nRawExpires\t:%s\nMaxAge\t\t:%v\nSecure\t\t:%v\nHttpOnly\t:%v\n-------------\n", c.Name, c.Value, c.Path, c.Domain, c.Expires, c.RawExpires, c.MaxAge, c.Secure, c.HttpOnly)
+	return fmt.Sprintf("\nName\t\t:%s\nValue\t\t:%s\nPath\t\t:%s\nDomain\t\t:%s\nExpires\t\t:%v\nRawExpires\t:%s\nExpiry\t\t:%v\nMaxAge\t\t:%v\nSecure\t\t:%v\nHttpOnly\t:%v\nHostOnly\t:%v\nSameSite\t:%s\n-------------\n", c.Name, c.Value, c.Path, c.Domain, c.Expires, c.RawExpires, c.Expiry, c.MaxAge, c.Secure, c.HttpOnly, c.HostOnly, c.SameSite)
 }
 
 func tohttpCookie(cookies []*cookie) (httpCookies []*http.Cookie) {
 	debug()
 
-	var expires time.Time
-	var err error
-
 	for i := 0; i < len(cookies); i++ {
 		// .Expires
-		expires, err = time.Parse(time.RFC1123, cookies[i].RawExpires)
-		if err == nil {
-			cookies[i].Expires = expires
+		switch {
+		case cookies[i].Expiry != 0:
+			cookies[i].Expires = time.Unix(cookies[i].Expiry, 0)
+
+		case cookies[i].RawExpires != "":
+			expires, ok := parseCookieTime(cookies[i].RawExpires)
+			if ok {
+				cookies[i].Expires = expires
+			}
 		}
 
 		// new httpCookie
@@ -110,6 +253,7 @@ func tohttpCookie(cookies []*cookie) (httpCookies []*http.Cookie) {
 			MaxAge:     cookies[i].MaxAge,
 			Secure:     cookies[i].Secure,
 			HttpOnly:   cookies[i].HttpOnly,
+			SameSite:   sameSiteMode(cookies[i].SameSite),
 		})
 	}
 
@@ -131,12 +275,38 @@ func toCookie(httpCookies []*http.Cookie) (cookies []*cookie) {
 			MaxAge:     httpCookies[i].MaxAge,
 			Secure:     httpCookies[i].Secure,
 			HttpOnly:   httpCookies[i].HttpOnly,
+			SameSite:   sameSiteString(httpCookies[i].SameSite),
 		})
 	}
 
 	return
 }
 
+// exportCookies returns the cookies sent to domain with all the attributes the jar knows
+func (c *Client) exportCookies(domain string) (cookies []*cookie, err error) {
+	jar, err := c.jar()
+	if err != nil {
+		httpCookies, err := c.GetCookies(domain)
+		if err != nil {
+			return nil, err
+		}
+
+		return toCookie(httpCookies), nil
+	}
+
+	u, err := url.Parse(domain)
+	if err != nil {
+		debug("ERR(parse)", err)
+		return
+	}
+
+	for _, e := range jar.matching(u, time.Now()) {
+		cookies = append(cookies, e.cookie())
+	}
+
+	return
+}
+
 // ImportCookie imports cookie from json
 func (c *Client) ImportCookie(domain, jsonStr string) (err error) {
 	debug("domain:", domain)
@@ -152,6 +322,13 @@ func (c *Client) ImportCookie(domain, jsonStr string) (err error) {
 
 	httpCookies := tohttpCookie(cookies)
 
+	// host cookies are set without domain attribute
+	for i := 0; i < len(cookies); i++ {
+		if cookies[i].HostOnly {
+			httpCookies[i].Domain = ""
+		}
+	}
+
 	err = c.SetCookies(domain, httpCookies)
 	if err != nil {
 		return
@@ -160,17 +337,15 @@ func (c *Client) ImportCookie(domain, jsonStr string) (err error) {
 }
 
 // ExportCookie exports client cookies as json
-// stdlib cookie jar just export cookie name and value
+// all the attributes are exported with a #Jar, other jars just export cookie name and value
 func (c *Client) ExportCookie(domain string) (jsonStr string, err error) {
 	debug("domain:", domain)
 
-	httpCookies, err := c.GetCookies(domain)
+	cookies, err := c.exportCookies(domain)
 	if err != nil {
 		return
 	}
 
-	cookies := toCookie(httpCookies)
-
 	var buf bytes.Buffer
 	enc := json.NewEncoder(&buf)
 	enc.SetEscapeHTML(true)
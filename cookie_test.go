@@ -1,8 +1,11 @@
 package request
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 	"testing"
+	"time"
 )
 
 var testCookieClient *Client
@@ -249,7 +252,7 @@ func TestExportCookie(t *testing.T) {
 	}
 
 	sample := `[{"name":"cookie1","value":"1","path":"","domain":"","secure":false,"httponly":false},{"name":"cookie2","value":"2","path":"","domain":"","secure":false,"httponly":false}]`
-	sampleCustomCookieJar := `[{"name":"cookie1","value":"1","path":"/","domain":"httpbin.org","secure":false,"httponly":false},{"name":"cookie2","value":"2","path":"/","domain":"httpbin.org","secure":false,"httponly":false}]`
+	sampleCustomCookieJar := `[{"name":"cookie1","value":"1","path":"/","domain":"httpbin.org","secure":false,"httponly":false,"hostonly":true},{"name":"cookie2","value":"2","path":"/","domain":"httpbin.org","secure":false,"httponly":false,"hostonly":true}]`
 
 	// NOTE: local test on a customize cookie jar
 	if jsonStr != sample && jsonStr != sampleCustomCookieJar {
@@ -257,3 +260,186 @@ func TestExportCookie(t *testing.T) {
 		return
 	}
 }
+
+func TestExportCookieFull(t *testing.T) {
+	client := New()
+
+	expires := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	err := client.SetCookies("https://www.example.com/app/login", []*http.Cookie{
+		{Name: "host", Value: "1", Path: "/app", Expires: expires, Secure: true, HttpOnly: true, SameSite: http.SameSiteStrictMode},
+		{Name: "domain", Value: "2", Domain: "example.com", MaxAge: 3600, SameSite: http.SameSiteLaxMode},
+		{Name: "session", Value: "3"},
+	})
+	if err != nil {
+		t.Error()
+		return
+	}
+
+	jsonStr, err := client.ExportCookie("https://www.example.com/app/")
+	if err != nil {
+		t.Error()
+		return
+	}
+
+	domainExpires := jarEntry(client, "domain").Expires.UTC()
+
+	sample := `[{"name":"host","value":"1","path":"/app","domain":"www.example.com","expires":"Fri, 01 Jan 2100 00:00:00 GMT","expiry":4102444800,"secure":true,"httponly":true,"hostonly":true,"samesite":"strict"},` +
+		`{"name":"domain","value":"2","path":"/app","domain":"example.com","expires":"` + domainExpires.Format(http.TimeFormat) + `","expiry":` + strconv.FormatInt(domainExpires.Unix(), 10) + `,"secure":false,"httponly":false,"samesite":"lax"},` +
+		`{"name":"session","value":"3","path":"/app","domain":"www.example.com","secure":false,"httponly":false,"hostonly":true}]`
+
+	if jsonStr != sample {
+		t.Error(jsonStr)
+		return
+	}
+
+	// round trip
+	imported := New()
+
+	err = imported.ImportCookie("https://www.example.com/app/login", jsonStr)
+	if err != nil {
+		t.Error()
+		return
+	}
+
+	reexported, err := imported.ExportCookie("https://www.example.com/app/")
+	if err != nil {
+		t.Error()
+		return
+	}
+
+	if reexported != jsonStr {
+		t.Error(reexported)
+		return
+	}
+}
+
+func TestImportCookieExpires(t *testing.T) {
+	expires := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	values := []string{
+		`"expires": "Fri, 01 Jan 2100 00:00:00 GMT"`,
+		`"expires": "Fri, 01-Jan-2100 00:00:00 GMT"`,
+		`"expires": "2100-01-01T00:00:00Z"`,
+		`"expires": "4102444800"`,
+		`"expiry": 4102444800`,
+	}
+
+	for _, value := range values {
+		client := New()
+
+		err := client.ImportCookie("http://example.com", `[{"name": "one", "value": "1", `+value+`}]`)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		e := jarEntry(client, "one")
+		if e == nil || !e.Expires.Equal(expires) {
+			t.Error(value, e)
+			return
+		}
+	}
+}
+
+func TestCookieManagement(t *testing.T) {
+	client := New()
+
+	client.SetCookies("https://www.example.com/", []*http.Cookie{
+		{Name: "one", Value: "1"},
+		{Name: "two", Value: "2", Domain: "example.com", Secure: true, MaxAge: 60},
+	})
+	client.SetCookies("https://api.example.com/v1/", []*http.Cookie{
+		{Name: "one", Value: "1", Path: "/v1"},
+		{Name: "one", Value: "2", Path: "/v2"},
+	})
+	client.SetCookies("http://example.org/", []*http.Cookie{
+		{Name: "three", Value: "3"},
+	})
+
+	domains, err := client.ListDomains()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if fmt.Sprint(domains) != "[api.example.com example.com example.org www.example.com]" {
+		t.Error(domains)
+		return
+	}
+
+	cookies, err := client.AllCookies()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(cookies) != 5 {
+		t.Error(len(cookies))
+		return
+	}
+
+	// example.com
+	if cookies[2].Name != "two" || cookies[2].HostOnly || !cookies[2].Secure || cookies[2].Expires.IsZero() {
+		t.Error(cookies[2])
+		return
+	}
+
+	// www.example.com
+	if cookies[4].Name != "one" || !cookies[4].HostOnly {
+		t.Error(cookies[4])
+		return
+	}
+
+	// every path
+	err = client.DeleteCookie("https://api.example.com", "one")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if s := cookieString(client.httpClient.Jar, "https://api.example.com/v1/"); s != "two=2" {
+		t.Error(s)
+		return
+	}
+
+	// subdomains too
+	err = client.ClearDomain("example.com")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	domains, _ = client.ListDomains()
+	if fmt.Sprint(domains) != "[example.org]" {
+		t.Error(domains)
+		return
+	}
+
+	err = client.ClearAll()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	domains, _ = client.ListDomains()
+	if len(domains) != 0 {
+		t.Error(domains)
+		return
+	}
+}
+
+func TestCookieManagementNoJar(t *testing.T) {
+	client := NewNoCookie()
+
+	_, err := client.ListDomains()
+	if err != ErrJar {
+		t.Error(err)
+		return
+	}
+
+	if client.ClearAll() != ErrJar {
+		t.Error()
+		return
+	}
+}
@@ -0,0 +1,402 @@
+package request
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CookieFormat is a cookie file format of other tools
+type CookieFormat int
+
+// cookie formats
+const (
+	CookieNetscape       CookieFormat = iota // cookies.txt of curl, wget and browsers
+	CookieEditThisCookie                     // json of the EditThisCookie browser extension
+	CookieHAR                                // json array of HAR cookies
+)
+
+// ErrCookieFormat is returned for an unknown #CookieFormat
+var ErrCookieFormat = errors.New("request: unknown cookie format")
+
+const netscapeHeader = "# Netscape HTTP Cookie File"
+
+// httpOnlyPrefix marks httponly cookies in cookies.txt
+const httpOnlyPrefix = "#HttpOnly_"
+
+// ImportCookieFormat imports cookies of any domain from data in format
+func (c *Client) ImportCookieFormat(format CookieFormat, data string) (err error) {
+	debug("format:", format)
+
+	var cookies []*cookie
+
+	switch format {
+	case CookieNetscape:
+		cookies, err = parseNetscape(data)
+
+	case CookieEditThisCookie:
+		cookies, err = parseEditThisCookie(data)
+
+	case CookieHAR:
+		cookies, err = parseHAR(data)
+
+	default:
+		err = ErrCookieFormat
+	}
+
+	if err != nil {
+		debug("ERR(parse)", err)
+		return
+	}
+
+	return c.setCookiesByDomain(cookies)
+}
+
+// ExportCookieFormat exports client cookies of domain in format
+// the cookies the jar does not know the domain of are exported as host cookies of domain
+func (c *Client) ExportCookieFormat(domain string, format CookieFormat) (data string, err error) {
+	debug("domain:", domain, "format:", format)
+
+	u, err := url.Parse(domain)
+	if err != nil {
+		debug("ERR(parse)", err)
+		return
+	}
+
+	cookies, err := c.exportCookies(domain)
+	if err != nil {
+		return
+	}
+
+	for _, ck := range cookies {
+		if ck.Domain == "" {
+			ck.Domain = u.Hostname()
+			ck.HostOnly = true
+		}
+
+		if ck.Path == "" {
+			ck.Path = "/"
+		}
+	}
+
+	switch format {
+	case CookieNetscape:
+		return formatNetscape(cookies), nil
+
+	case CookieEditThisCookie:
+		return formatEditThisCookie(cookies)
+
+	case CookieHAR:
+		return formatHAR(cookies)
+	}
+
+	err = ErrCookieFormat
+	return
+}
+
+// setCookiesByDomain sets each cookie on the url of its own domain
+func (c *Client) setCookiesByDomain(cookies []*cookie) (err error) {
+	httpCookies := tohttpCookie(cookies)
+
+	var urls []string
+	byURL := map[string][]*http.Cookie{}
+
+	for i, httpCookie := range httpCookies {
+		host := strings.TrimPrefix(httpCookie.Domain, ".")
+		if host == "" {
+			return fmt.Errorf("request: cookie %q has no domain", httpCookie.Name)
+		}
+
+		// host cookies are set without domain attribute
+		if cookies[i].HostOnly {
+			httpCookie.Domain = ""
+		}
+
+		scheme := "http"
+		if httpCookie.Secure {
+			scheme = "https"
+		}
+
+		u := scheme + "://" + host + "/"
+
+		if _, ok := byURL[u]; !ok {
+			urls = append(urls, u)
+		}
+
+		byURL[u] = append(byURL[u], httpCookie)
+	}
+
+	for _, u := range urls {
+		err = c.SetCookies(u, byURL[u])
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// parseNetscape parses cookies.txt lines
+// domain, include subdomains, path, secure, expiry, name, value separated by tabs
+func parseNetscape(data string) (cookies []*cookie, err error) {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		if httpOnly {
+			line = strings.TrimPrefix(line, httpOnlyPrefix)
+		}
+
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+
+		// empty value without trailing tab
+		if len(fields) == 6 {
+			fields = append(fields, "")
+		}
+
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("request: cookies.txt line %d: %d fields", lineNumber, len(fields))
+		}
+
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("request: cookies.txt line %d: %v", lineNumber, err)
+		}
+
+		ck := &cookie{
+			Domain:   fields[0],
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+
+		// 0 is a session cookie
+		if expiry > 0 {
+			ck.Expires = time.Unix(expiry, 0)
+		}
+
+		cookies = append(cookies, ck)
+	}
+
+	err = scanner.Err()
+	return
+}
+
+func formatNetscape(cookies []*cookie) string {
+	var b strings.Builder
+
+	b.WriteString(netscapeHeader + "\n\n")
+
+	for _, ck := range cookies {
+		domain := ck.Domain
+		includeSubdomains := "FALSE"
+
+		if !ck.HostOnly {
+			includeSubdomains = "TRUE"
+
+			if !strings.HasPrefix(domain, ".") {
+				domain = "." + domain
+			}
+		}
+
+		if ck.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+
+		var expiry int64
+		if !ck.Expires.IsZero() {
+			expiry = ck.Expires.Unix()
+		}
+
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, includeSubdomains, ck.Path, strings.ToUpper(strconv.FormatBool(ck.Secure)), expiry, ck.Name, ck.Value)
+	}
+
+	return b.String()
+}
+
+// editThisCookie is a cookie of the EditThisCookie extension
+type editThisCookie struct {
+	Domain         string  `json:"domain"`
+	ExpirationDate float64 `json:"expirationDate,omitempty"` // unix time in second with fraction
+	HostOnly       bool    `json:"hostOnly"`
+	HttpOnly       bool    `json:"httpOnly"`
+	Name           string  `json:"name"`
+	Path           string  `json:"path"`
+	SameSite       string  `json:"sameSite,omitempty"`
+	Secure         bool    `json:"secure"`
+	Session        bool    `json:"session"`
+	StoreID        string  `json:"storeId,omitempty"`
+	Value          string  `json:"value"`
+	ID             int     `json:"id,omitempty"`
+}
+
+// editThisCookieSameSite maps the EditThisCookie sameSite to cookie.SameSite
+var editThisCookieSameSite = map[string]string{
+	"no_restriction": "none",
+	"lax":            "lax",
+	"strict":         "strict",
+	"unspecified":    "",
+}
+
+func parseEditThisCookie(data string) (cookies []*cookie, err error) {
+	var exported []*editThisCookie
+
+	err = json.Unmarshal([]byte(data), &exported)
+	if err != nil {
+		return
+	}
+
+	for _, e := range exported {
+		ck := &cookie{
+			Name:     e.Name,
+			Value:    e.Value,
+			Path:     e.Path,
+			Domain:   e.Domain,
+			HostOnly: e.HostOnly,
+			Secure:   e.Secure,
+			HttpOnly: e.HttpOnly,
+			SameSite: editThisCookieSameSite[e.SameSite],
+		}
+
+		if !e.Session && e.ExpirationDate > 0 {
+			ck.Expires = unixFloat(e.ExpirationDate)
+		}
+
+		cookies = append(cookies, ck)
+	}
+
+	return
+}
+
+func formatEditThisCookie(cookies []*cookie) (data string, err error) {
+	exported := []*editThisCookie{}
+
+	for i, ck := range cookies {
+		e := &editThisCookie{
+			Domain:   ck.Domain,
+			HostOnly: ck.HostOnly,
+			HttpOnly: ck.HttpOnly,
+			Name:     ck.Name,
+			Path:     ck.Path,
+			Secure:   ck.Secure,
+			SameSite: "unspecified",
+			Session:  ck.Expires.IsZero(),
+			Value:    ck.Value,
+			ID:       i + 1,
+		}
+
+		for sameSite, value := range editThisCookieSameSite {
+			if value != "" && value == ck.SameSite {
+				e.SameSite = sameSite
+			}
+		}
+
+		if !e.HostOnly && !strings.HasPrefix(e.Domain, ".") {
+			e.Domain = "." + e.Domain
+		}
+
+		if !e.Session {
+			e.ExpirationDate = float64(ck.Expires.UnixNano()) / 1e9
+		}
+
+		exported = append(exported, e)
+	}
+
+	b, err := json.Marshal(exported)
+	if err != nil {
+		return
+	}
+
+	data = string(b)
+	return
+}
+
+// harCookie is a cookie of a HAR request or response
+type harCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"` // ISO 8601
+	HttpOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+func parseHAR(data string) (cookies []*cookie, err error) {
+	var exported []*harCookie
+
+	err = json.Unmarshal([]byte(data), &exported)
+	if err != nil {
+		return
+	}
+
+	for _, h := range exported {
+		ck := &cookie{
+			Name:     h.Name,
+			Value:    h.Value,
+			Path:     h.Path,
+			Domain:   h.Domain,
+			Secure:   h.Secure,
+			HttpOnly: h.HttpOnly,
+		}
+
+		if h.Expires != "" {
+			ck.Expires, err = time.Parse(time.RFC3339Nano, h.Expires)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		cookies = append(cookies, ck)
+	}
+
+	return
+}
+
+func formatHAR(cookies []*cookie) (data string, err error) {
+	exported := []*harCookie{}
+
+	for _, ck := range cookies {
+		h := &harCookie{
+			Name:     ck.Name,
+			Value:    ck.Value,
+			Path:     ck.Path,
+			Domain:   ck.Domain,
+			HttpOnly: ck.HttpOnly,
+			Secure:   ck.Secure,
+		}
+
+		if !ck.Expires.IsZero() {
+			h.Expires = ck.Expires.UTC().Format(time.RFC3339Nano)
+		}
+
+		exported = append(exported, h)
+	}
+
+	b, err := json.Marshal(exported)
+	if err != nil {
+		return
+	}
+
+	data = string(b)
+	return
+}
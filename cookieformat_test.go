@@ -0,0 +1,257 @@
+package request
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// jarEntry returns the jar entry of client named name, nil if not found
+func jarEntry(client *Client, name string) *entry {
+	jar := client.httpClient.Jar.(*Jar)
+
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	for _, submap := range jar.entries {
+		for _, e := range submap {
+			if e.Name == name {
+				return &e
+			}
+		}
+	}
+
+	return nil
+}
+
+func TestImportCookieNetscape(t *testing.T) {
+	client := New()
+
+	err := client.ImportCookieFormat(CookieNetscape, strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		"",
+		".example.com\tTRUE\t/\tFALSE\t4102444800\tdomain\t1",
+		"www.example.com\tFALSE\t/\tTRUE\t0\thost\t2",
+		"#HttpOnly_.example.com\tTRUE\t/app\tFALSE\t0\thttponly\t3",
+		".example.com\tTRUE\t/\tFALSE\t946684800\texpired\t4",
+		"www.example.com\tFALSE\t/\tFALSE\t0\tempty",
+	}, "\n"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if s := cookieString(client.httpClient.Jar, "https://www.example.com/app"); s != "httponly=3 domain=1 host=2 empty=" {
+		t.Error(s)
+		return
+	}
+
+	if s := cookieString(client.httpClient.Jar, "http://api.example.com/"); s != "domain=1" {
+		t.Error(s)
+		return
+	}
+
+	e := jarEntry(client, "domain")
+	if !e.Persistent || e.Expires.Unix() != 4102444800 || e.HostOnly {
+		t.Error(e)
+		return
+	}
+
+	e = jarEntry(client, "host")
+	if !e.Secure || !e.HostOnly || e.Persistent {
+		t.Error(e)
+		return
+	}
+
+	if e = jarEntry(client, "httponly"); !e.HttpOnly {
+		t.Error(e)
+		return
+	}
+}
+
+func TestImportCookieNetscapeBadLine(t *testing.T) {
+	client := New()
+
+	err := client.ImportCookieFormat(CookieNetscape, "example.com\tTRUE\t/")
+	if err == nil {
+		t.Error()
+		return
+	}
+
+	err = client.ImportCookieFormat(CookieNetscape, "example.com\tTRUE\t/\tFALSE\tnever\tname\tvalue")
+	if err == nil {
+		t.Error()
+		return
+	}
+}
+
+func TestImportCookieEditThisCookie(t *testing.T) {
+	client := New()
+
+	err := client.ImportCookieFormat(CookieEditThisCookie, `[{
+		"domain": ".example.com",
+		"expirationDate": 4102444800.5,
+		"hostOnly": false,
+		"httpOnly": true,
+		"name": "one",
+		"path": "/",
+		"sameSite": "lax",
+		"secure": false,
+		"session": false,
+		"storeId": "0",
+		"value": "1",
+		"id": 1
+	}, {
+		"domain": "www.example.com",
+		"hostOnly": true,
+		"httpOnly": false,
+		"name": "two",
+		"path": "/",
+		"secure": true,
+		"session": true,
+		"value": "2",
+		"id": 2
+	}]`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if s := cookieString(client.httpClient.Jar, "https://www.example.com/"); s != "one=1 two=2" {
+		t.Error(s)
+		return
+	}
+
+	e := jarEntry(client, "one")
+	if !e.HttpOnly || e.Expires.Unix() != 4102444800 {
+		t.Error(e)
+		return
+	}
+
+	if e = jarEntry(client, "two"); !e.HostOnly || !e.Secure || e.Persistent {
+		t.Error(e)
+		return
+	}
+}
+
+func TestImportCookieHAR(t *testing.T) {
+	client := New()
+
+	err := client.ImportCookieFormat(CookieHAR, `[{
+		"name": "one",
+		"value": "1",
+		"path": "/",
+		"domain": "example.com",
+		"expires": "2100-01-01T00:00:00.000Z",
+		"httpOnly": true,
+		"secure": true
+	}]`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if s := cookieString(client.httpClient.Jar, "https://api.example.com/"); s != "one=1" {
+		t.Error(s)
+		return
+	}
+
+	e := jarEntry(client, "one")
+	if !e.HttpOnly || !e.Secure || !e.Expires.Equal(time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error(e)
+		return
+	}
+
+	// no domain
+	err = client.ImportCookieFormat(CookieHAR, `[{"name": "two", "value": "2"}]`)
+	if err == nil {
+		t.Error()
+		return
+	}
+}
+
+func TestImportCookieFormatUnknown(t *testing.T) {
+	err := New().ImportCookieFormat(CookieFormat(-1), "")
+	if err != ErrCookieFormat {
+		t.Error(err)
+		return
+	}
+}
+
+func TestExportCookieFormat(t *testing.T) {
+	client := New()
+
+	err := client.ImportCookieFormat(CookieNetscape, "www.example.com\tFALSE\t/\tFALSE\t0\tone\t1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	data, err := client.ExportCookieFormat("http://www.example.com", CookieNetscape)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if data != "# Netscape HTTP Cookie File\n\nwww.example.com\tFALSE\t/\tFALSE\t0\tone\t1\n" {
+		t.Error(data)
+		return
+	}
+
+	data, err = client.ExportCookieFormat("http://www.example.com", CookieEditThisCookie)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if data != `[{"domain":"www.example.com","hostOnly":true,"httpOnly":false,"name":"one","path":"/","sameSite":"unspecified","secure":false,"session":true,"value":"1","id":1}]` {
+		t.Error(data)
+		return
+	}
+
+	data, err = client.ExportCookieFormat("http://www.example.com", CookieHAR)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if data != `[{"name":"one","value":"1","path":"/","domain":"www.example.com"}]` {
+		t.Error(data)
+		return
+	}
+}
+
+func TestCookieFormatRoundTrip(t *testing.T) {
+	cookies, err := parseNetscape("#HttpOnly_.example.com\tTRUE\t/app\tTRUE\t4102444800\tone\t1\n")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	cases := []struct {
+		format func([]*cookie) (string, error)
+		parse  func(string) ([]*cookie, error)
+	}{
+		{formatEditThisCookie, parseEditThisCookie},
+		{formatHAR, parseHAR},
+	}
+
+	for _, c := range cases {
+		data, err := c.format(cookies)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		parsed, err := c.parse(data)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if formatNetscape(parsed) != formatNetscape(cookies) {
+			t.Error(formatNetscape(parsed))
+			return
+		}
+	}
+}
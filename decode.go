@@ -0,0 +1,91 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	// MaxSnippetSize is the max number of body bytes kept by #DecodeError
+	MaxSnippetSize = 512
+)
+
+// ErrContentType is returned when Option.Result or Option.ErrorResult is set
+// and the response is not json
+var ErrContentType = errors.New("request: response is not json")
+
+// DecodeError is returned when the response cannot be decoded into
+// Option.Result or Option.ErrorResult
+type DecodeError struct {
+	StatusCode  int
+	ContentType string
+	Snippet     string // beginning of the body, up to MaxSnippetSize bytes
+	Err         error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("request: decode %d response: %v: %q", e.StatusCode, e.Err, e.Snippet)
+}
+
+// Unwrap returns the json or content type error
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// decodeResult decodes data into opt.Result for a 2xx response
+// and into opt.ErrorResult otherwise
+func decodeResult(res *http.Response, data []byte, opt *Option) (err error) {
+	target := opt.Result
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		target = opt.ErrorResult
+	}
+
+	// nothing to decode, like 204
+	if target == nil || len(data) == 0 {
+		return
+	}
+
+	contentType := res.Header.Get("Content-Type")
+
+	if !isJSON(contentType) {
+		err = ErrContentType
+	} else {
+		err = json.Unmarshal(data, target)
+	}
+
+	if err == nil {
+		return
+	}
+
+	debug("ERR(decode)", err)
+
+	snippet := data
+	if len(snippet) > MaxSnippetSize {
+		snippet = snippet[:MaxSnippetSize]
+	}
+
+	return &DecodeError{
+		StatusCode:  res.StatusCode,
+		ContentType: contentType,
+		Snippet:     string(snippet),
+		Err:         err,
+	}
+}
+
+// isJSON returns true for application/json, application/*+json and no content type
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
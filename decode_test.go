@@ -0,0 +1,182 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type decodeUser struct {
+	Name string `json:"name"`
+}
+
+type decodeAPIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// newDecodeServer answers the status and content type of the query with the body of the query
+func newDecodeServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		if contentType := query.Get("type"); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+
+		status := http.StatusOK
+		json.Unmarshal([]byte(query.Get("status")), &status)
+
+		w.WriteHeader(status)
+		w.Write([]byte(query.Get("body")))
+	}))
+}
+
+func TestDecodeResult(t *testing.T) {
+	ts := newDecodeServer()
+	defer ts.Close()
+
+	client := New()
+
+	var user decodeUser
+	var apiErr decodeAPIError
+
+	_, _, err := client.Request(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"type": []string{"application/json; charset=utf-8"},
+			"body": []string{`{"name":"ddo"}`},
+		},
+		Result:      &user,
+		ErrorResult: &apiErr,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if user.Name != "ddo" || apiErr.Code != "" {
+		t.Error(user, apiErr)
+		return
+	}
+
+	res, err := client.Do(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"type":   []string{"application/problem+json"},
+			"status": []string{"422"},
+			"body":   []string{`{"code":"invalid","message":"name is required"}`},
+		},
+		Result:      &decodeUser{},
+		ErrorResult: &apiErr,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.StatusCode() != 422 || apiErr.Code != "invalid" || apiErr.Message != "name is required" {
+		t.Error(res.Status(), apiErr)
+		return
+	}
+}
+
+func TestDecodeResultEmpty(t *testing.T) {
+	ts := newDecodeServer()
+	defer ts.Close()
+
+	client := New()
+
+	user := decodeUser{Name: "ddo"}
+
+	_, _, err := client.Request(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"status": []string{"204"},
+		},
+		Result: &user,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if user.Name != "ddo" {
+		t.Error(user)
+		return
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	ts := newDecodeServer()
+	defer ts.Close()
+
+	client := New()
+
+	// not json
+	_, res, err := client.Request(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"type":   []string{"text/html"},
+			"status": []string{"502"},
+			"body":   []string{"<html>bad gateway</html>"},
+		},
+		ErrorResult: &decodeAPIError{},
+	})
+
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || !errors.Is(err, ErrContentType) {
+		t.Error(err)
+		return
+	}
+
+	if res == nil || decodeErr.StatusCode != 502 || decodeErr.ContentType != "text/html" || decodeErr.Snippet != "<html>bad gateway</html>" {
+		t.Error(decodeErr)
+		return
+	}
+
+	// invalid json, long body
+	body := `{"name":` + strings.Repeat("1", MaxSnippetSize)
+
+	_, _, err = client.Request(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"type": []string{"application/json"},
+			"body": []string{body},
+		},
+		Result: &decodeUser{},
+	})
+
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &decodeErr) || !errors.As(err, &syntaxErr) {
+		t.Error(err)
+		return
+	}
+
+	if decodeErr.StatusCode != 200 || decodeErr.Snippet != body[:MaxSnippetSize] {
+		t.Error(decodeErr)
+		return
+	}
+}
+
+func TestIsJSON(t *testing.T) {
+	cases := map[string]bool{
+		"":                                true,
+		"application/json":                true,
+		"application/json; charset=utf-8": true,
+		"application/vnd.api+json":        true,
+		"text/plain":                      false,
+		"text/html; charset=utf-8":        false,
+		";;":                              false,
+	}
+
+	for contentType, expected := range cases {
+		if isJSON(contentType) != expected {
+			t.Error(contentType)
+			return
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package request
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// body encodings of Option.Body
+const (
+	EncodingRaw  = ""     // url encoded, no Content-Type header, unrelated to EncodingOption.Raw
+	EncodingForm = "form" // same as Option.Form
+	EncodingJSON = "json" // same as Option.JSON, single values as string, others as array
+)
+
+// Defaults holds the client settings merged into every #Option
+//
+// merge rules, the #Option always wins:
+//   - BaseURL: a relative Option.URL is resolved against BaseURL like a path in a directory,
+//     "users" and "/v1/users" on "https://api.com/v1" give "https://api.com/v1/users",
+//     an absolute Option.URL is used as is
+//   - Method: used when Option.Method is empty
+//   - Header: added when Option.Header does not have the key
+//   - Query: added when Option.Query does not have the key
+//   - Encoding: how Option.Body is encoded when no other body is set
+type Defaults struct {
+	BaseURL  string
+	Method   string
+	Header   *Header
+	Query    *Data
+	Encoding string // default: EncodingRaw
+}
+
+// SetDefaults sets client default settings, nil removes them
+func (c *Client) SetDefaults(defaults *Defaults) {
+	debug(defaults)
+
+	c.defaults = defaults
+}
+
+// merge returns a copy of opt with the defaults applied
+func (d *Defaults) merge(opt *Option) (merged *Option, err error) {
+	if d == nil {
+		return opt, nil
+	}
+
+	copied := *opt
+	merged = &copied
+
+	merged.URL, err = resolveURL(d.BaseURL, opt.URL)
+	if err != nil {
+		debug("ERR(resolveURL)", err)
+		return
+	}
+
+	if merged.Method == "" {
+		merged.Method = d.Method
+	}
+
+	if d.Header != nil {
+		header := Header{}
+
+		for key, value := range *d.Header {
+			header[http.CanonicalHeaderKey(key)] = value
+		}
+
+		if opt.Header != nil {
+			for key, value := range *opt.Header {
+				header[http.CanonicalHeaderKey(key)] = value
+			}
+		}
+
+		merged.Header = &header
+	}
+
+	if d.Query != nil {
+		query := Data{}
+
+		for key, value := range *d.Query {
+			query[key] = value
+		}
+
+		if opt.Query != nil {
+			for key, value := range *opt.Query {
+				query[key] = value
+			}
+		}
+
+		merged.Query = &query
+	}
+
+	if opt.Body != nil && opt.BodyStr == "" && opt.JSON == nil && opt.Form == nil && opt.Files == nil {
+		switch d.Encoding {
+		case EncodingForm:
+			merged.Form, merged.Body = opt.Body, nil
+
+		case EncodingJSON:
+			merged.JSON, merged.Body = dataJSON(opt.Body), nil
+		}
+	}
+
+	return
+}
+
+// resolveURL resolves urlStr against base
+func resolveURL(base, urlStr string) (string, error) {
+	if base == "" {
+		return urlStr, nil
+	}
+
+	ref, err := url.Parse(urlStr)
+	if err != nil {
+		return "", err
+	}
+
+	if ref.IsAbs() {
+		return urlStr, nil
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	// base path is a directory
+	if !strings.HasSuffix(baseURL.Path, "/") {
+		baseURL.Path += "/"
+	}
+
+	// absolute paths stay under the base path unless they already start with it or are it
+	underBase := strings.HasPrefix(ref.Path, baseURL.Path) || ref.Path == strings.TrimSuffix(baseURL.Path, "/")
+
+	if strings.HasPrefix(ref.Path, "/") && !underBase {
+		ref.Path = strings.TrimPrefix(ref.Path, "/")
+		ref.RawPath = ""
+	}
+
+	return baseURL.ResolveReference(ref).String(), nil
+}
+
+// dataJSON converts data to a json friendly map
+func dataJSON(data *Data) map[string]interface{} {
+	obj := map[string]interface{}{}
+
+	for key, slice := range *data {
+		if len(slice) == 1 {
+			obj[key] = slice[0]
+			continue
+		}
+
+		obj[key] = slice
+	}
+
+	return obj
+}
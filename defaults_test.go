@@ -0,0 +1,165 @@
+package request
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type defaultsRes struct {
+	Method      string              `json:"method"`
+	Path        string              `json:"path"`
+	Query       map[string][]string `json:"query"`
+	Header      map[string][]string `json:"header"`
+	ContentType string              `json:"content_type"`
+	Body        string              `json:"body"`
+}
+
+// newInspectServer returns a local server that echoes the request as json
+func newInspectServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		json.NewEncoder(w).Encode(defaultsRes{
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			Query:       r.URL.Query(),
+			Header:      r.Header,
+			ContentType: r.Header.Get("Content-Type"),
+			Body:        string(body),
+		})
+	}))
+}
+
+func TestResolveURL(t *testing.T) {
+	cases := [][3]string{
+		{"", "https://httpbin.org/get", "https://httpbin.org/get"},
+		{"https://api.com/v1", "users", "https://api.com/v1/users"},
+		{"https://api.com/v1/", "users?one=1", "https://api.com/v1/users?one=1"},
+		{"https://api.com/v1", "/users", "https://api.com/v1/users"},
+		{"https://api.com/v1", "/v1/users", "https://api.com/v1/users"},
+		{"https://api.com/v1", "/v1", "https://api.com/v1"},
+		{"https://api.com/v1/", "/v1?x=1", "https://api.com/v1?x=1"},
+		{"https://api.com/v1", "", "https://api.com/v1/"},
+		{"https://api.com/v1", "https://httpbin.org/get", "https://httpbin.org/get"},
+	}
+
+	for _, c := range cases {
+		u, err := resolveURL(c[0], c[1])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if u != c[2] {
+			t.Error(c, u)
+			return
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	ts := newInspectServer()
+	defer ts.Close()
+
+	client := New()
+	client.SetDefaults(&Defaults{
+		BaseURL: ts.URL + "/v1",
+		Method:  "post",
+		Header: &Header{
+			"authorization": "Bearer one",
+			"X-Two":         "2",
+		},
+		Query: &Data{
+			"key":   []string{"default"},
+			"three": []string{"3"},
+		},
+		Encoding: EncodingForm,
+	})
+
+	opt := &Option{
+		URL: "users",
+		Header: &Header{
+			"X-Two": "hai",
+		},
+		Query: &Data{
+			"key": []string{"mine"},
+		},
+		Body: &Data{
+			"email": []string{"ddo@ddo.me"},
+		},
+	}
+
+	data, _, err := client.Request(opt)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var testData defaultsRes
+	json.Unmarshal(data, &testData)
+
+	if testData.Method != "POST" || testData.Path != "/v1/users" {
+		t.Error(testData.Method, testData.Path)
+		return
+	}
+
+	if testData.Header["Authorization"][0] != "Bearer one" || testData.Header["X-Two"][0] != "hai" {
+		t.Error(testData.Header)
+		return
+	}
+
+	if testData.Query["key"][0] != "mine" || testData.Query["three"][0] != "3" {
+		t.Error(testData.Query)
+		return
+	}
+
+	if testData.ContentType != "application/x-www-form-urlencoded" || testData.Body != "email=ddo%40ddo.me" {
+		t.Error(testData.ContentType, testData.Body)
+		return
+	}
+
+	// the option is not modified
+	if opt.URL != "users" || opt.Method != "" || len(*opt.Header) != 1 {
+		t.Error(opt)
+		return
+	}
+}
+
+func TestDefaultsJSON(t *testing.T) {
+	ts := newInspectServer()
+	defer ts.Close()
+
+	client := New()
+	client.SetDefaults(&Defaults{
+		BaseURL:  ts.URL,
+		Encoding: EncodingJSON,
+	})
+
+	data, _, err := client.Request(&Option{
+		Method: "PUT",
+		Body: &Data{
+			"one":   []string{"1"},
+			"three": []string{"3", "ba"},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var testData defaultsRes
+	json.Unmarshal(data, &testData)
+
+	if testData.Method != "PUT" || testData.Path != "/" {
+		t.Error(testData.Method, testData.Path)
+		return
+	}
+
+	if testData.ContentType != "application/json" || testData.Body != `{"one":"1","three":["3","ba"]}` {
+		t.Error(testData.ContentType, testData.Body)
+		return
+	}
+}
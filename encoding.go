@@ -0,0 +1,258 @@
+package request
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// content codings of Content-Encoding and Accept-Encoding,
+// not to be mixed with the body encodings of Defaults.Encoding
+const (
+	ContentGzip    = "gzip"
+	ContentDeflate = "deflate"
+	ContentBrotli  = "br"
+	ContentZstd    = "zstd"
+)
+
+// DefaultContentEncodings are the content codings advertised by default, preferred first
+var DefaultContentEncodings = []string{ContentZstd, ContentBrotli, ContentGzip, ContentDeflate}
+
+// ErrEncoding is returned for a response content encoding that cannot be decoded
+var ErrEncoding = errors.New("request: unsupported content encoding")
+
+// EncodingOption holds the #Client Accept-Encoding negotiation
+type EncodingOption struct {
+	Accept []string // advertised, preferred first, default: DefaultContentEncodings
+	Raw    bool     // the response body is returned as sent, still encoded
+}
+
+type encodingKey struct{}
+
+// bodyEncoding is filled when the response of an attempt is received
+type bodyEncoding struct {
+	encoding string
+	raw      *countBody // nil if unknown
+}
+
+// SetEncoding sets client Accept-Encoding negotiation
+// the responses are decoded unless opt.Raw, Option.Header Accept-Encoding overrides the advertised ones
+// nil restores the http.Transport implicit gzip
+func (c *Client) SetEncoding(opt *EncodingOption) {
+	debug(opt)
+
+	if opt != nil && len(opt.Accept) == 0 {
+		opt = &EncodingOption{Accept: DefaultContentEncodings, Raw: opt.Raw}
+	}
+
+	c.encoding = opt
+}
+
+// ContentEncoding returns the Content-Encoding res was sent with,
+// "" if none, even when it is decoded
+func ContentEncoding(res *http.Response) string {
+	if res == nil || res.Request == nil {
+		return ""
+	}
+
+	state, _ := res.Request.Context().Value(encodingKey{}).(*bodyEncoding)
+	if state == nil {
+		return res.Header.Get("Content-Encoding")
+	}
+
+	return state.encoding
+}
+
+// CompressedSize returns the number of body bytes of res read from the wire so far,
+// -1 if unknown, like with the http.Transport implicit gzip
+func CompressedSize(res *http.Response) int64 {
+	if res == nil || res.Request == nil {
+		return -1
+	}
+
+	state, _ := res.Request.Context().Value(encodingKey{}).(*bodyEncoding)
+	if state == nil || state.raw == nil {
+		return -1
+	}
+
+	return state.raw.count()
+}
+
+// withBodyEncoding returns ctx with an empty body encoding
+func withBodyEncoding(ctx context.Context) context.Context {
+	return context.WithValue(ctx, encodingKey{}, &bodyEncoding{})
+}
+
+// setAcceptEncoding advertises the client encodings on req
+func (c *Client) setAcceptEncoding(req *http.Request) {
+	if c.encoding == nil || req.Header.Get("Accept-Encoding") != "" {
+		return
+	}
+
+	req.Header.Set("Accept-Encoding", strings.Join(c.encoding.Accept, ", "))
+}
+
+// decodeBody records the encoding of res and decodes its body
+func (c *Client) decodeBody(res *http.Response) (err error) {
+	state, _ := res.Request.Context().Value(encodingKey{}).(*bodyEncoding)
+	if state == nil {
+		return
+	}
+
+	state.encoding = res.Header.Get("Content-Encoding")
+	state.raw = nil
+
+	// decoded by http.Transport
+	if res.Uncompressed {
+		state.encoding = ContentGzip
+		return
+	}
+
+	state.raw = &countBody{rc: res.Body, done: func(int64, []byte) {}}
+	res.Body = state.raw
+
+	if c.encoding == nil || c.encoding.Raw {
+		return
+	}
+
+	// no body to decode
+	if res.Request.Method == "HEAD" || res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusNotModified {
+		return
+	}
+
+	var encodings []string
+
+	for _, encoding := range strings.Split(state.encoding, ",") {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+
+		switch encoding {
+		case "", "identity":
+			continue
+
+		case "x-gzip":
+			encoding = ContentGzip
+
+		case ContentGzip, ContentDeflate, ContentBrotli, ContentZstd:
+
+		default:
+			res.Body.Close()
+			return fmt.Errorf("%w: %s", ErrEncoding, encoding)
+		}
+
+		encodings = append(encodings, encoding)
+	}
+
+	if len(encodings) == 0 {
+		return
+	}
+
+	debug("DECODE", state.encoding)
+
+	res.Body = &decodedBody{rc: res.Body, encodings: encodings}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+
+	return
+}
+
+// decodedBody decodes rc on the first read, the encodings are in the applied order
+type decodedBody struct {
+	rc        io.ReadCloser
+	encodings []string
+
+	r       io.Reader
+	closers []io.Closer
+	err     error
+}
+
+func (b *decodedBody) Read(p []byte) (n int, err error) {
+	if b.r == nil && b.err == nil {
+		b.r, b.err = b.decoder()
+	}
+
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	return b.r.Read(p)
+}
+
+// decoder returns the reader of the decoded body, the last encoding applied is undone first
+func (b *decodedBody) decoder() (r io.Reader, err error) {
+	br := bufio.NewReader(b.rc)
+
+	// an empty body, io.EOF
+	_, err = br.Peek(1)
+	if err != nil {
+		return
+	}
+
+	r = br
+
+	for i := len(b.encodings) - 1; i >= 0; i-- {
+		switch b.encodings[i] {
+		case ContentGzip:
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+
+			b.closers = append(b.closers, gz)
+			r = gz
+
+		case ContentDeflate:
+			r = newDeflateReader(r)
+			b.closers = append(b.closers, r.(io.Closer))
+
+		case ContentBrotli:
+			r = brotli.NewReader(r)
+
+		case ContentZstd:
+			zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+
+			rc := zr.IOReadCloser()
+			b.closers = append(b.closers, rc)
+			r = rc
+		}
+	}
+
+	return
+}
+
+func (b *decodedBody) Close() error {
+	for _, closer := range b.closers {
+		closer.Close()
+	}
+
+	return b.rc.Close()
+}
+
+// newDeflateReader reads the zlib format, or raw deflate sent by some servers
+func newDeflateReader(r io.Reader) io.ReadCloser {
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		zr, err := zlib.NewReader(br)
+		if err == nil {
+			return zr
+		}
+	}
+
+	return flate.NewReader(br)
+}
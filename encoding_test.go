@@ -0,0 +1,289 @@
+package request
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+var encodingBody = strings.Repeat("hello encoding ", 100)
+
+// encode returns data encoded with encoding, "raw-deflate" is deflate without zlib header
+func encode(encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+
+	switch encoding {
+	case ContentGzip:
+		w = gzip.NewWriter(&buf)
+	case ContentDeflate:
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case ContentBrotli:
+		w = brotli.NewWriter(&buf)
+	case ContentZstd:
+		w, _ = zstd.NewWriter(&buf)
+	default:
+		return data
+	}
+
+	w.Write(data)
+	w.Close()
+
+	return buf.Bytes()
+}
+
+// newEncodingServer encodes encodingBody with the ?enc= encodings, in order,
+// and echoes Accept-Encoding in X-Accept-Encoding
+func newEncodingServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+
+		encodings := r.URL.Query()["enc"]
+
+		// http.Transport implicit gzip
+		if len(encodings) == 0 && r.Header.Get("Accept-Encoding") == "gzip" {
+			encodings = []string{ContentGzip}
+		}
+
+		data := []byte(encodingBody)
+		if r.Method == "HEAD" {
+			data = nil
+		}
+
+		for _, encoding := range encodings {
+			data = encode(encoding, data)
+		}
+
+		if len(encodings) > 0 {
+			w.Header().Set("Content-Encoding", strings.Replace(strings.Join(encodings, ", "), "raw-deflate", "deflate", 1))
+		}
+
+		w.Write(data)
+	}))
+}
+
+func TestEncoding(t *testing.T) {
+	ts := newEncodingServer()
+	defer ts.Close()
+
+	client := New()
+	client.SetEncoding(&EncodingOption{})
+
+	cases := map[string]string{
+		ContentGzip:    ContentGzip,
+		ContentDeflate: ContentDeflate,
+		"raw-deflate":  ContentDeflate,
+		ContentBrotli:  ContentBrotli,
+		ContentZstd:    ContentZstd,
+	}
+
+	for enc, contentEncoding := range cases {
+		res, err := client.Do(&Option{
+			URL: ts.URL,
+			Query: &Data{
+				"enc": []string{enc},
+			},
+		})
+		if err != nil {
+			t.Error(enc, err)
+			return
+		}
+
+		if res.String() != encodingBody || res.ContentEncoding() != contentEncoding {
+			t.Error(enc, res.ContentEncoding(), res.String())
+			return
+		}
+
+		if res.CompressedSize() <= 0 || res.CompressedSize() >= int64(len(encodingBody)) {
+			t.Error(enc, res.CompressedSize())
+			return
+		}
+
+		if res.HeaderValue("Content-Encoding") != "" || res.HeaderValue("X-Accept-Encoding") != "zstd, br, gzip, deflate" {
+			t.Error(enc, res.Header())
+			return
+		}
+	}
+
+	// stacked
+	res, err := client.Do(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"enc": []string{ContentDeflate, ContentGzip},
+		},
+	})
+	if err != nil || res.String() != encodingBody || res.ContentEncoding() != "deflate, gzip" {
+		t.Error(err, res.ContentEncoding())
+		return
+	}
+
+	// empty
+	res, err = client.Do(&Option{
+		URL:    ts.URL,
+		Method: "HEAD",
+		Query: &Data{
+			"enc": []string{ContentGzip},
+		},
+	})
+	if err != nil || res.String() != "" {
+		t.Error(err)
+		return
+	}
+
+	// none
+	res, err = client.Do(&Option{
+		URL: ts.URL,
+	})
+	if err != nil || res.String() != encodingBody || res.ContentEncoding() != "" || res.CompressedSize() != int64(len(encodingBody)) {
+		t.Error(err, res.ContentEncoding(), res.CompressedSize())
+		return
+	}
+}
+
+func TestEncodingAccept(t *testing.T) {
+	ts := newEncodingServer()
+	defer ts.Close()
+
+	client := New()
+	client.SetEncoding(&EncodingOption{
+		Accept: []string{ContentBrotli},
+	})
+
+	res, _ := client.Do(&Option{
+		URL: ts.URL,
+	})
+	if res.HeaderValue("X-Accept-Encoding") != "br" {
+		t.Error(res.Header())
+		return
+	}
+
+	// the option wins
+	res, _ = client.Do(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"enc": []string{ContentZstd},
+		},
+		Header: &Header{
+			"Accept-Encoding": "zstd",
+		},
+	})
+	if res.HeaderValue("X-Accept-Encoding") != "zstd" || res.String() != encodingBody {
+		t.Error(res.Header())
+		return
+	}
+}
+
+func TestEncodingRaw(t *testing.T) {
+	ts := newEncodingServer()
+	defer ts.Close()
+
+	client := New()
+	client.SetEncoding(&EncodingOption{Raw: true})
+
+	res, err := client.Do(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"enc": []string{ContentZstd},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !bytes.Equal(res.Bytes(), encode(ContentZstd, []byte(encodingBody))) {
+		t.Error(res.String())
+		return
+	}
+
+	if res.ContentEncoding() != ContentZstd || res.HeaderValue("Content-Encoding") != ContentZstd || res.CompressedSize() != int64(len(res.Bytes())) {
+		t.Error(res.Header(), res.CompressedSize())
+		return
+	}
+}
+
+func TestEncodingDefault(t *testing.T) {
+	ts := newEncodingServer()
+	defer ts.Close()
+
+	res, err := New().Do(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// decoded by http.Transport
+	if res.String() != encodingBody || res.ContentEncoding() != ContentGzip || res.CompressedSize() != -1 {
+		t.Error(res.ContentEncoding(), res.CompressedSize())
+		return
+	}
+}
+
+func TestEncodingUnsupported(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "compress")
+		w.Write([]byte("data"))
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.SetEncoding(&EncodingOption{})
+
+	_, _, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if !errors.Is(err, ErrEncoding) {
+		t.Error(err)
+		return
+	}
+}
+
+func TestEncodingEmpty(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", r.URL.Query().Get("enc"))
+
+		switch r.URL.Query().Get("status") {
+		case "204":
+			w.WriteHeader(http.StatusNoContent)
+		case "304":
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.SetEncoding(&EncodingOption{})
+
+	for _, enc := range []string{ContentGzip, ContentDeflate, ContentBrotli, ContentZstd} {
+		for _, method := range []string{"HEAD", "GET"} {
+			for _, status := range []string{"200", "204", "304"} {
+				res, err := client.Do(&Option{
+					URL:    ts.URL,
+					Method: method,
+					Query: &Data{
+						"enc":    []string{enc},
+						"status": []string{status},
+					},
+				})
+				if err != nil || res.String() != "" || res.ContentEncoding() != enc {
+					t.Error(enc, method, status, err)
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package request
+
+import (
+	"net/http"
+)
+
+// BeforeHook is called before every attempt of a request
+// it can mutate req, an error aborts the request
+type BeforeHook func(req *http.Request) error
+
+// AfterHook is called after the response data is read
+// it can inspect or replace res and data, an error is returned by #Request
+// data is nil for #Stream
+type AfterHook func(res *http.Response, data []byte) (*http.Response, []byte, error)
+
+// DoFunc sends a request, like http.RoundTripper
+type DoFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the sending of every attempt of a request
+type Middleware func(next DoFunc) DoFunc
+
+// hooks holds the client middleware chain
+type hooks struct {
+	before      []BeforeHook
+	after       []AfterHook
+	middlewares []Middleware
+}
+
+// BeforeRequest adds hooks called in order before every attempt
+// it is not safe to add hooks while requests are in flight
+func (c *Client) BeforeRequest(hook ...BeforeHook) {
+	c.hooks.before = append(c.hooks.before, hook...)
+}
+
+// AfterResponse adds hooks called in order after every response
+// it is not safe to add hooks while requests are in flight
+func (c *Client) AfterResponse(hook ...AfterHook) {
+	c.hooks.after = append(c.hooks.after, hook...)
+}
+
+// Use adds middlewares, the first one added is the outermost
+// it is not safe to add middlewares while requests are in flight
+func (c *Client) Use(middleware ...Middleware) {
+	c.hooks.middlewares = append(c.hooks.middlewares, middleware...)
+}
+
+// runBefore calls the before hooks in order, stops on the first error
+func (c *Client) runBefore(req *http.Request) (err error) {
+	for _, hook := range c.hooks.before {
+		err = hook(req)
+		if err != nil {
+			debug("ERR(before)", err)
+			return
+		}
+	}
+
+	return
+}
+
+// runAfter calls the after hooks in order, stops on the first error
+func (c *Client) runAfter(res *http.Response, data []byte) (*http.Response, []byte, error) {
+	var err error
+
+	for _, hook := range c.hooks.after {
+		res, data, err = hook(res, data)
+		if err != nil {
+			debug("ERR(after)", err)
+			return res, data, err
+		}
+	}
+
+	return res, data, nil
+}
+
+// roundTrip sends req through the middleware chain
+func (c *Client) roundTrip(req *http.Request) (*http.Response, error) {
+	next := DoFunc(c.httpClient.Do)
+
+	for i := len(c.hooks.middlewares) - 1; i >= 0; i-- {
+		next = c.hooks.middlewares[i](next)
+	}
+
+	return next(req)
+}
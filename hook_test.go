@@ -0,0 +1,209 @@
+package request
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newEchoServer returns a local server that echoes the X-Echo header
+func newEchoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Join(r.Header["X-Echo"], ",")))
+	}))
+}
+
+func TestBeforeRequest(t *testing.T) {
+	ts := newEchoServer()
+	defer ts.Close()
+
+	client := New()
+	client.BeforeRequest(func(req *http.Request) error {
+		req.Header.Add("X-Echo", "one")
+		return nil
+	}, func(req *http.Request) error {
+		req.Header.Add("X-Echo", "two")
+		return nil
+	})
+
+	data, _, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if string(data) != "one,two" {
+		t.Error(string(data))
+		return
+	}
+}
+
+func TestBeforeRequestError(t *testing.T) {
+	ts := newEchoServer()
+	defer ts.Close()
+
+	errHook := errors.New("hook")
+
+	client := New()
+	client.BeforeRequest(func(req *http.Request) error {
+		return errHook
+	})
+
+	_, res, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != errHook {
+		t.Error(err)
+		return
+	}
+
+	if res != nil {
+		t.Error()
+		return
+	}
+}
+
+func TestAfterResponse(t *testing.T) {
+	ts := newEchoServer()
+	defer ts.Close()
+
+	var status int
+
+	client := New()
+	client.AfterResponse(func(res *http.Response, data []byte) (*http.Response, []byte, error) {
+		status = res.StatusCode
+		return res, append(data, "two"...), nil
+	}, func(res *http.Response, data []byte) (*http.Response, []byte, error) {
+		return res, append(data, "three"...), nil
+	})
+
+	data, _, err := client.Request(&Option{
+		URL: ts.URL,
+		Header: &Header{
+			"X-Echo": "one",
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if status != 200 {
+		t.Error(status)
+		return
+	}
+
+	if string(data) != "onetwothree" {
+		t.Error(string(data))
+		return
+	}
+}
+
+func TestAfterResponseStream(t *testing.T) {
+	ts := newEchoServer()
+	defer ts.Close()
+
+	client := New()
+	client.AfterResponse(func(res *http.Response, data []byte) (*http.Response, []byte, error) {
+		if data != nil {
+			t.Error()
+		}
+
+		res.Body = ioutil.NopCloser(strings.NewReader("replaced"))
+		return res, data, nil
+	})
+
+	body, _, err := client.Stream(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer body.Close()
+
+	data, _ := ioutil.ReadAll(body)
+	if string(data) != "replaced" {
+		t.Error(string(data))
+		return
+	}
+}
+
+func TestUse(t *testing.T) {
+	ts := newEchoServer()
+	defer ts.Close()
+
+	var order []string
+
+	client := New()
+	client.Use(func(next DoFunc) DoFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			order = append(order, "outer")
+			req.Header.Add("X-Echo", "outer")
+
+			res, err := next(req)
+
+			order = append(order, "outer done")
+			return res, err
+		}
+	}, func(next DoFunc) DoFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			order = append(order, "inner")
+			req.Header.Add("X-Echo", "inner")
+
+			res, err := next(req)
+
+			order = append(order, "inner done")
+			return res, err
+		}
+	})
+
+	data, _, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if string(data) != "outer,inner" {
+		t.Error(string(data))
+		return
+	}
+
+	if strings.Join(order, ",") != "outer,inner,inner done,outer done" {
+		t.Error(order)
+		return
+	}
+}
+
+func TestUseShortCircuit(t *testing.T) {
+	client := New()
+	client.Use(func(next DoFunc) DoFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 204,
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		}
+	})
+
+	_, res, err := client.Request(&Option{
+		URL: "http://127.0.0.1:1",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.StatusCode != 204 {
+		t.Error(res.StatusCode)
+		return
+	}
+}
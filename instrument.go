@@ -0,0 +1,383 @@
+package request
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrorClass is the class of a failed attempt
+type ErrorClass string
+
+// error classes
+const (
+	ErrorNone     ErrorClass = ""
+	ErrorCanceled ErrorClass = "canceled"
+	ErrorTimeout  ErrorClass = "timeout"
+	ErrorDNS      ErrorClass = "dns"
+	ErrorConnect  ErrorClass = "connect"
+	ErrorTLS      ErrorClass = "tls"
+	ErrorOther    ErrorClass = "other"
+	ErrorClient   ErrorClass = "4xx"
+	ErrorServer   ErrorClass = "5xx"
+)
+
+// Metrics are the measures of an attempt
+// the phases of every connection of the redirects add up
+type Metrics struct {
+	Method  string
+	URL     string // after redirects, without password
+	Attempt int
+
+	StatusCode int
+	ErrorClass ErrorClass
+	Err        error
+
+	Start      time.Time
+	DNS        time.Duration
+	Connect    time.Duration
+	TLS        time.Duration
+	FirstByte  time.Duration // since Start, of the last response
+	Total      time.Duration // since Start, until the response body is closed
+	ConnReused bool
+
+	BytesSent     int64 // request body
+	BytesReceived int64 // response body
+}
+
+// Instrument measures the client attempts
+type Instrument interface {
+	// Start is called before an attempt is sent, it returns the attempt context
+	Start(ctx context.Context, req *http.Request) context.Context
+
+	// Finish is called once the attempt failed or its response body is closed
+	Finish(ctx context.Context, metrics *Metrics)
+}
+
+// SetInstrument sets client instrument, nil turns it off
+func (c *Client) SetInstrument(instrument Instrument) {
+	debug()
+
+	c.instrument = instrument
+}
+
+// attemptTrace records the httptrace events of an attempt
+type attemptTrace struct {
+	mu      sync.Mutex
+	metrics Metrics
+
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+}
+
+// startInstrument returns req with the instrument and httptrace context
+// and the func to call with the attempt result
+func (c *Client) startInstrument(req *http.Request) (*http.Request, func(res *http.Response, err error)) {
+	if c.instrument == nil {
+		return req, func(*http.Response, error) {}
+	}
+
+	attempt, ok := req.Context().Value(attemptKey{}).(int)
+	if !ok {
+		attempt = 1
+	}
+
+	t := &attemptTrace{
+		metrics: Metrics{
+			Method:  req.Method,
+			URL:     req.URL.Redacted(),
+			Attempt: attempt,
+			Start:   time.Now(),
+		},
+	}
+
+	ctx := c.instrument.Start(req.Context(), req)
+	ctx = httptrace.WithClientTrace(ctx, t.clientTrace())
+
+	req = req.WithContext(ctx)
+
+	var sent *countBody
+
+	if req.Body != nil && req.Body != http.NoBody {
+		sent = &countBody{rc: req.Body, done: func(int64, []byte) {}}
+		req.Body = sent
+	}
+
+	finish := func(res *http.Response, err error) {
+		t.mu.Lock()
+		m := t.metrics
+		t.mu.Unlock()
+
+		if sent != nil {
+			m.BytesSent = sent.count()
+		}
+
+		if err != nil {
+			m.Err = err
+			m.ErrorClass = errorClass(err)
+			m.Total = time.Now().Sub(m.Start)
+
+			c.instrument.Finish(ctx, &m)
+			return
+		}
+
+		m.URL = res.Request.URL.Redacted()
+		m.StatusCode = res.StatusCode
+		m.ErrorClass = statusClass(res.StatusCode)
+
+		res.Body = &countBody{
+			rc: res.Body,
+			done: func(n int64, _ []byte) {
+				m.BytesReceived = n
+				m.Total = time.Now().Sub(m.Start)
+
+				c.instrument.Finish(ctx, &m)
+			},
+		}
+	}
+
+	return req, finish
+}
+
+func (t *attemptTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.metrics.DNS += time.Now().Sub(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			t.mu.Lock()
+			t.connectStart = time.Now()
+			t.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mu.Lock()
+			t.metrics.Connect += time.Now().Sub(t.connectStart)
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.metrics.TLS += time.Now().Sub(t.tlsStart)
+			t.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.metrics.ConnReused = info.Reused
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.metrics.FirstByte = time.Now().Sub(t.metrics.Start)
+			t.mu.Unlock()
+		},
+	}
+}
+
+// errorClass returns the class of an attempt error
+func errorClass(err error) ErrorClass {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var netErr net.Error
+	var recordErr tls.RecordHeaderError
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certErr x509.CertificateInvalidError
+
+	switch {
+	case errors.Is(err, ErrCanceled), errors.Is(err, context.Canceled):
+		return ErrorCanceled
+
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+
+	case errors.As(err, &dnsErr):
+		return ErrorDNS
+
+	case errors.Is(err, ErrPinMismatch), errors.As(err, &recordErr), errors.As(err, &verifyErr),
+		errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &certErr):
+		return ErrorTLS
+
+	case errors.As(err, &opErr) && opErr.Op == "dial", errors.Is(err, ErrNoProxy):
+		return ErrorConnect
+	}
+
+	return ErrorOther
+}
+
+// statusClass returns the class of a status code
+func statusClass(code int) ErrorClass {
+	switch {
+	case code >= 500:
+		return ErrorServer
+
+	case code >= 400:
+		return ErrorClient
+	}
+
+	return ErrorNone
+}
+
+// Collector is an in-memory #Instrument
+type Collector struct {
+	mu      sync.Mutex
+	metrics []*Metrics
+}
+
+// NewCollector returns a new empty Collector
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Start implements #Instrument
+func (c *Collector) Start(ctx context.Context, req *http.Request) context.Context {
+	return ctx
+}
+
+// Finish implements #Instrument
+func (c *Collector) Finish(ctx context.Context, metrics *Metrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.metrics = append(c.metrics, metrics)
+}
+
+// Metrics returns the collected metrics in finish order
+func (c *Collector) Metrics() []*Metrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]*Metrics{}, c.metrics...)
+}
+
+// Percentile returns the p (0 to 100) percentile of the total durations
+func (c *Collector) Percentile(p float64) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.metrics) == 0 {
+		return 0
+	}
+
+	durations := make([]time.Duration, len(c.metrics))
+	for i, m := range c.metrics {
+		durations[i] = m.Total
+	}
+
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+
+	// nearest rank
+	rank := int(math.Ceil(p/100*float64(len(durations)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+
+	if rank >= len(durations) {
+		rank = len(durations) - 1
+	}
+
+	return durations[rank]
+}
+
+// Reset removes the collected metrics
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.metrics = nil
+}
+
+// Span is the part of an OpenTelemetry span used by #NewSpanInstrument
+type Span interface {
+	SetAttributes(attrs map[string]interface{})
+	AddEvent(name string, at time.Time)
+	RecordError(err error)
+	End(at time.Time)
+}
+
+// Tracer starts spans, like an OpenTelemetry tracer
+type Tracer interface {
+	Start(ctx context.Context, name string, at time.Time) (context.Context, Span)
+}
+
+type spanKey struct{}
+
+// spanInstrument reports the attempts as spans
+type spanInstrument struct {
+	tracer Tracer
+}
+
+// NewSpanInstrument returns an #Instrument that starts a span of tracer per attempt
+// with the OpenTelemetry http attribute names
+func NewSpanInstrument(tracer Tracer) Instrument {
+	return &spanInstrument{tracer: tracer}
+}
+
+func (s *spanInstrument) Start(ctx context.Context, req *http.Request) context.Context {
+	ctx, span := s.tracer.Start(ctx, "HTTP "+req.Method, time.Now())
+
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func (s *spanInstrument) Finish(ctx context.Context, m *Metrics) {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	if !ok {
+		return
+	}
+
+	attrs := map[string]interface{}{
+		"http.request.method":        m.Method,
+		"url.full":                   m.URL,
+		"http.request.resend_count":  m.Attempt - 1,
+		"http.request.body.size":     m.BytesSent,
+		"http.response.body.size":    m.BytesReceived,
+		"request.dns.duration":       m.DNS.Seconds(),
+		"request.connect.duration":   m.Connect.Seconds(),
+		"request.tls.duration":       m.TLS.Seconds(),
+		"request.connection.reused":  m.ConnReused,
+		"request.first_byte.seconds": m.FirstByte.Seconds(),
+	}
+
+	if m.StatusCode != 0 {
+		attrs["http.response.status_code"] = m.StatusCode
+	}
+
+	if m.ErrorClass != ErrorNone {
+		attrs["error.type"] = string(m.ErrorClass)
+	}
+
+	span.SetAttributes(attrs)
+
+	if m.FirstByte > 0 {
+		span.AddEvent("first_byte", m.Start.Add(m.FirstByte))
+	}
+
+	if m.Err != nil {
+		span.RecordError(m.Err)
+	}
+
+	span.End(m.Start.Add(m.Total))
+}
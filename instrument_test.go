@@ -0,0 +1,277 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSpan is an offline #Span
+type testSpan struct {
+	name   string
+	attrs  map[string]interface{}
+	events []string
+	err    error
+	start  time.Time
+	end    time.Time
+}
+
+func (s *testSpan) SetAttributes(attrs map[string]interface{}) {
+	s.attrs = attrs
+}
+
+func (s *testSpan) AddEvent(name string, at time.Time) {
+	s.events = append(s.events, name)
+}
+
+func (s *testSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *testSpan) End(at time.Time) {
+	s.end = at
+}
+
+// testTracer is an offline #Tracer
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, at time.Time) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := &testSpan{name: name, start: at}
+	t.spans = append(t.spans, span)
+
+	return ctx, span
+}
+
+func TestCollector(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	collector := NewCollector()
+
+	client := New()
+	client.SetInstrument(collector)
+	client.SetTLS(&TLSOption{
+		CAPEM:      certPEM(ts.Certificate()),
+		ServerName: "example.com",
+	})
+
+	for i := 0; i < 2; i++ {
+		_, _, err := client.Request(&Option{
+			URL:     "https://localhost:" + port,
+			Method:  "POST",
+			BodyStr: "ping",
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	metrics := collector.Metrics()
+	if len(metrics) != 2 {
+		t.Error(metrics)
+		return
+	}
+
+	m := metrics[0]
+
+	if m.Method != "POST" || m.StatusCode != 200 || m.ErrorClass != ErrorNone || m.Attempt != 1 {
+		t.Error(m)
+		return
+	}
+
+	if m.Connect <= 0 || m.TLS <= 0 || m.FirstByte <= 0 || m.Total < m.FirstByte || m.ConnReused {
+		t.Error(m)
+		return
+	}
+
+	if m.BytesSent != 4 || m.BytesReceived != 5 {
+		t.Error(m.BytesSent, m.BytesReceived)
+		return
+	}
+
+	// keep-alive
+	if !metrics[1].ConnReused || metrics[1].TLS != 0 {
+		t.Error(metrics[1])
+		return
+	}
+
+	if collector.Percentile(100) != maxTotal(metrics) || collector.Percentile(0) > collector.Percentile(50) {
+		t.Error(collector.Percentile(100))
+		return
+	}
+
+	collector.Reset()
+
+	if len(collector.Metrics()) != 0 || collector.Percentile(50) != 0 {
+		t.Error(collector.Metrics())
+		return
+	}
+}
+
+func maxTotal(metrics []*Metrics) (max time.Duration) {
+	for _, m := range metrics {
+		if m.Total > max {
+			max = m.Total
+		}
+	}
+
+	return
+}
+
+func TestCollectorErrors(t *testing.T) {
+	down := httptest.NewServer(nil)
+	down.Close()
+
+	slow := newSlowServer(time.Second)
+	defer slow.Close()
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	collector := NewCollector()
+
+	client := New()
+	client.SetInstrument(collector)
+
+	client.Request(&Option{
+		URL: down.URL,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	client.RequestContext(ctx, &Option{
+		URL: slow.URL,
+	})
+
+	client.Request(&Option{
+		URL: missing.URL,
+	})
+
+	metrics := collector.Metrics()
+	if len(metrics) != 3 {
+		t.Error(metrics)
+		return
+	}
+
+	classes := []ErrorClass{ErrorConnect, ErrorTimeout, ErrorClient}
+
+	for i, class := range classes {
+		if metrics[i].ErrorClass != class {
+			t.Error(i, metrics[i].ErrorClass, metrics[i].Err)
+			return
+		}
+	}
+
+	if metrics[0].Err == nil || metrics[2].StatusCode != 404 {
+		t.Error(metrics[0], metrics[2])
+		return
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	cases := map[ErrorClass]error{
+		ErrorCanceled: ErrCanceled,
+		ErrorTimeout:  context.DeadlineExceeded,
+		ErrorDNS:      &net.DNSError{Err: "no such host", Name: "nowhere"},
+		ErrorTLS:      ErrPinMismatch,
+		ErrorConnect:  &net.OpError{Op: "dial", Err: errors.New("refused")},
+		ErrorOther:    errors.New("other"),
+	}
+
+	for class, err := range cases {
+		if errorClass(err) != class {
+			t.Error(class, err)
+			return
+		}
+	}
+
+	if statusClass(200) != ErrorNone || statusClass(302) != ErrorNone || statusClass(503) != ErrorServer {
+		t.Error()
+		return
+	}
+}
+
+func TestSpanInstrument(t *testing.T) {
+	ts, _ := newFlakyServer(1, http.StatusBadGateway, nil)
+	defer ts.Close()
+
+	tracer := &testTracer{}
+
+	client := New()
+	client.SetRetry(newTestRetryPolicy())
+	client.SetInstrument(NewSpanInstrument(tracer))
+
+	_, _, err := client.Request(&Option{
+		URL: strings.Replace(ts.URL, "http://", "http://user:secret@", 1),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(tracer.spans) != 2 {
+		t.Error(tracer.spans)
+		return
+	}
+
+	failed, span := tracer.spans[0], tracer.spans[1]
+
+	if failed.attrs["http.response.status_code"] != http.StatusBadGateway || failed.attrs["error.type"] != "5xx" {
+		t.Error(failed.attrs)
+		return
+	}
+
+	if span.name != "HTTP GET" || span.attrs["http.response.status_code"] != 200 || span.attrs["http.request.resend_count"] != 1 {
+		t.Error(span.name, span.attrs)
+		return
+	}
+
+	if _, ok := span.attrs["error.type"]; ok || span.err != nil {
+		t.Error(span.attrs)
+		return
+	}
+
+	// no password
+	if span.attrs["url.full"] != strings.Replace(ts.URL, "http://", "http://user:xxxxx@", 1) {
+		t.Error(span.attrs["url.full"])
+		return
+	}
+
+	if len(span.events) != 1 || span.events[0] != "first_byte" || !span.end.After(span.start) {
+		t.Error(span.events, span.start, span.end)
+		return
+	}
+
+	// error
+	down := httptest.NewServer(nil)
+	down.Close()
+
+	client.SetRetry(nil)
+	client.Request(&Option{
+		URL: down.URL,
+	})
+
+	span = tracer.spans[2]
+	if span.err == nil || span.attrs["error.type"] != "connect" {
+		t.Error(span.err, span.attrs)
+		return
+	}
+}
@@ -0,0 +1,738 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+var (
+	errIllegalDomain   = errors.New("request: illegal cookie domain attribute")
+	errMalformedDomain = errors.New("request: malformed cookie domain attribute")
+)
+
+// JarOption holds the #Jar settings
+type JarOption struct {
+	Filename         string                     // json file the jar is loaded from and saved to, default: memory only
+	PublicSuffixList cookiejar.PublicSuffixList // default: publicsuffix.List
+}
+
+// Jar is an http.CookieJar that keeps every cookie attribute
+// it follows RFC 6265 like net/http/cookiejar
+// with a Filename, the jar is loaded on NewJar and saved on every change
+type Jar struct {
+	psl      cookiejar.PublicSuffixList
+	filename string
+
+	mu         sync.Mutex
+	entries    map[string]map[string]entry // eTLD+1 > domain;path;name > entry
+	nextSeqNum uint64
+
+	// serializes the file writes
+	saveMu sync.Mutex
+}
+
+// entry is a cookie with all the attributes the jar knows about
+type entry struct {
+	Name       string    `json:"name"`
+	Value      string    `json:"value"`
+	Domain     string    `json:"domain"`
+	Path       string    `json:"path"`
+	SameSite   string    `json:"samesite,omitempty"`
+	Secure     bool      `json:"secure"`
+	HttpOnly   bool      `json:"httponly"`
+	Persistent bool      `json:"persistent"`
+	HostOnly   bool      `json:"hostonly"`
+	Expires    time.Time `json:"expires"`
+	Creation   time.Time `json:"creation"`
+	LastAccess time.Time `json:"lastaccess"`
+
+	// insertion order, breaks ties of Creation
+	seqNum uint64
+}
+
+// NewJar returns a new Jar, opt can be nil
+// the file at opt.Filename is loaded if it exists
+func NewJar(opt *JarOption) (jar *Jar, err error) {
+	if opt == nil {
+		opt = &JarOption{}
+	}
+
+	jar = &Jar{
+		psl:      opt.PublicSuffixList,
+		filename: opt.Filename,
+		entries:  map[string]map[string]entry{},
+	}
+
+	if jar.psl == nil {
+		jar.psl = publicsuffix.List
+	}
+
+	if jar.filename == "" {
+		return
+	}
+
+	err = jar.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// NewWithJar returns a new Client that saves cookies in jar
+func NewWithJar(jar http.CookieJar) *Client {
+	debug()
+	return newClient(jar)
+}
+
+// Cookies implements http.CookieJar
+// it returns the name and value of the cookies to send to u
+func (j *Jar) Cookies(u *url.URL) (cookies []*http.Cookie) {
+	return j.cookies(u, time.Now())
+}
+
+// SetCookies implements http.CookieJar
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if j.setCookies(u, cookies, time.Now()) {
+		j.autoSave()
+	}
+}
+
+// Cookie is a cookie stored in a #Jar with all its attributes
+// Domain is the host for host only cookies
+type Cookie struct {
+	http.Cookie
+	HostOnly bool // not sent to subdomains of Domain
+}
+
+// Domains returns the sorted domains the jar has cookies for
+func (j *Jar) Domains() (domains []string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.prune(time.Now())
+
+	seen := map[string]bool{}
+
+	for _, e := range j.sorted() {
+		if !seen[e.Domain] {
+			seen[e.Domain] = true
+			domains = append(domains, e.Domain)
+		}
+	}
+
+	return
+}
+
+// All returns all the cookies sorted by domain, path and creation
+func (j *Jar) All() (cookies []*Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.prune(time.Now())
+
+	for _, e := range j.sorted() {
+		c := &Cookie{
+			Cookie: http.Cookie{
+				Name:     e.Name,
+				Value:    e.Value,
+				Path:     e.Path,
+				Domain:   e.Domain,
+				Secure:   e.Secure,
+				HttpOnly: e.HttpOnly,
+				SameSite: sameSiteMode(e.SameSite),
+			},
+			HostOnly: e.HostOnly,
+		}
+
+		if e.Persistent {
+			c.Expires = e.Expires
+		}
+
+		cookies = append(cookies, c)
+	}
+
+	return
+}
+
+// Delete removes the cookies named name of domain, any path
+// it returns the number of cookies removed
+func (j *Jar) Delete(domain, name string) int {
+	return j.remove(func(e entry) bool {
+		return e.Domain == domain && e.Name == name
+	})
+}
+
+// ClearDomain removes the cookies of domain and its subdomains
+// it returns the number of cookies removed
+func (j *Jar) ClearDomain(domain string) int {
+	return j.remove(func(e entry) bool {
+		return e.Domain == domain || hasDotSuffix(e.Domain, domain)
+	})
+}
+
+// Clear removes all the cookies
+func (j *Jar) Clear() int {
+	return j.remove(func(e entry) bool {
+		return true
+	})
+}
+
+// remove removes the entries match returns true for and saves the jar if needed
+func (j *Jar) remove(match func(e entry) bool) (removed int) {
+	j.mu.Lock()
+
+	for key, submap := range j.entries {
+		for id, e := range submap {
+			if match(e) {
+				delete(submap, id)
+				removed++
+			}
+		}
+
+		if len(submap) == 0 {
+			delete(j.entries, key)
+		}
+	}
+
+	j.mu.Unlock()
+
+	if removed > 0 {
+		j.autoSave()
+	}
+
+	return
+}
+
+// Save writes the jar to its file atomically, expired cookies are pruned
+func (j *Jar) Save() (err error) {
+	if j.filename == "" {
+		return
+	}
+
+	j.saveMu.Lock()
+	defer j.saveMu.Unlock()
+
+	j.mu.Lock()
+	j.prune(time.Now())
+	entries := j.sorted()
+	j.mu.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		debug("ERR(json.Marshal)", err)
+		return
+	}
+
+	return writeFileAtomic(j.filename, data)
+}
+
+func (j *Jar) autoSave() {
+	err := j.Save()
+	if err != nil {
+		debug("ERR(save)", err)
+	}
+}
+
+// load reads the jar file, a missing file is an empty jar
+func (j *Jar) load() (err error) {
+	data, err := ioutil.ReadFile(j.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		debug("ERR(ReadFile)", err)
+		return
+	}
+
+	var entries []entry
+
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		debug("ERR(json.Unmarshal)", err)
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, e := range entries {
+		j.put(e)
+	}
+
+	j.prune(time.Now())
+	return
+}
+
+// put stores e as is, j.mu must be held
+func (j *Jar) put(e entry) {
+	key := jarKey(e.Domain, j.psl)
+
+	submap := j.entries[key]
+	if submap == nil {
+		submap = map[string]entry{}
+		j.entries[key] = submap
+	}
+
+	e.seqNum = j.nextSeqNum
+	j.nextSeqNum++
+
+	submap[e.id()] = e
+}
+
+// prune removes the expired cookies, j.mu must be held
+func (j *Jar) prune(now time.Time) {
+	for key, submap := range j.entries {
+		for id, e := range submap {
+			if e.expired(now) {
+				delete(submap, id)
+			}
+		}
+
+		if len(submap) == 0 {
+			delete(j.entries, key)
+		}
+	}
+}
+
+// sorted returns all the entries by domain, path and creation, j.mu must be held
+func (j *Jar) sorted() (entries []entry) {
+	entries = []entry{}
+
+	for _, submap := range j.entries {
+		for _, e := range submap {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].Domain != entries[b].Domain {
+			return entries[a].Domain < entries[b].Domain
+		}
+		if entries[a].Path != entries[b].Path {
+			return entries[a].Path < entries[b].Path
+		}
+		return entries[a].seqNum < entries[b].seqNum
+	})
+
+	return
+}
+
+func (j *Jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie) {
+	for _, e := range j.matching(u, now) {
+		cookies = append(cookies, &http.Cookie{Name: e.Name, Value: e.Value})
+	}
+
+	return
+}
+
+// matching returns the entries to send to u in sending order
+func (j *Jar) matching(u *url.URL, now time.Time) (selected []entry) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return
+	}
+
+	key := jarKey(host, j.psl)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	submap := j.entries[key]
+	if submap == nil {
+		return
+	}
+
+	https := u.Scheme == "https"
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	for id, e := range submap {
+		if e.expired(now) {
+			delete(submap, id)
+			continue
+		}
+
+		if !e.shouldSend(https, host, path) {
+			continue
+		}
+
+		e.LastAccess = now
+		submap[id] = e
+		selected = append(selected, e)
+	}
+
+	if len(submap) == 0 {
+		delete(j.entries, key)
+	}
+
+	// longer paths first, then older cookies first
+	sort.Slice(selected, func(a, b int) bool {
+		s := selected
+		if len(s[a].Path) != len(s[b].Path) {
+			return len(s[a].Path) > len(s[b].Path)
+		}
+		if !s[a].Creation.Equal(s[b].Creation) {
+			return s[a].Creation.Before(s[b].Creation)
+		}
+		return s[a].seqNum < s[b].seqNum
+	})
+
+	return
+}
+
+// setCookies returns true if the jar is modified
+func (j *Jar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) (modified bool) {
+	if len(cookies) == 0 {
+		return
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return
+	}
+
+	key := jarKey(host, j.psl)
+	defPath := defaultPath(u.Path)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	submap := j.entries[key]
+
+	for _, cookie := range cookies {
+		e, remove, err := j.newEntry(cookie, now, defPath, host)
+		if err != nil {
+			debug("ERR(cookie)", cookie.Name, err)
+			continue
+		}
+
+		id := e.id()
+
+		if remove {
+			if _, ok := submap[id]; ok {
+				delete(submap, id)
+				modified = true
+			}
+			continue
+		}
+
+		if submap == nil {
+			submap = map[string]entry{}
+		}
+
+		if old, ok := submap[id]; ok {
+			e.Creation = old.Creation
+			e.seqNum = old.seqNum
+		} else {
+			e.Creation = now
+			e.seqNum = j.nextSeqNum
+			j.nextSeqNum++
+		}
+
+		e.LastAccess = now
+		submap[id] = e
+		modified = true
+	}
+
+	if !modified {
+		return
+	}
+
+	if len(submap) == 0 {
+		delete(j.entries, key)
+	} else {
+		j.entries[key] = submap
+	}
+
+	return
+}
+
+// newEntry creates the entry of c received from host
+// remove is true if c deletes the cookie
+func (j *Jar) newEntry(c *http.Cookie, now time.Time, defPath, host string) (e entry, remove bool, err error) {
+	e.Name = c.Name
+
+	if c.Path == "" || c.Path[0] != '/' {
+		e.Path = defPath
+	} else {
+		e.Path = c.Path
+	}
+
+	e.Domain, e.HostOnly, err = j.domainAndType(host, c.Domain)
+	if err != nil {
+		return
+	}
+
+	// MaxAge takes precedence over Expires
+	switch {
+	case c.MaxAge < 0:
+		return e, true, nil
+
+	case c.MaxAge > 0:
+		e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		e.Persistent = true
+
+	case !c.Expires.IsZero():
+		if !c.Expires.After(now) {
+			return e, true, nil
+		}
+
+		e.Expires = c.Expires
+		e.Persistent = true
+	}
+
+	e.Value = c.Value
+	e.Secure = c.Secure
+	e.HttpOnly = c.HttpOnly
+	e.SameSite = sameSiteString(c.SameSite)
+	return
+}
+
+// domainAndType returns the cookie domain and whether it is host only
+func (j *Jar) domainAndType(host, domain string) (string, bool, error) {
+	if domain == "" {
+		return host, true, nil
+	}
+
+	if isIP(host) {
+		if host != domain {
+			return "", false, errIllegalDomain
+		}
+
+		return host, true, nil
+	}
+
+	// a leading dot is ignored
+	if domain[0] == '.' {
+		domain = domain[1:]
+	}
+
+	if len(domain) == 0 || domain[0] == '.' || domain[len(domain)-1] == '.' {
+		return "", false, errMalformedDomain
+	}
+
+	domain = strings.ToLower(domain)
+
+	// no cookie for a public suffix, except as a host cookie of the suffix itself
+	if ps := j.psl.PublicSuffix(domain); ps != "" && !hasDotSuffix(domain, ps) {
+		if host == domain {
+			return host, true, nil
+		}
+
+		return "", false, errIllegalDomain
+	}
+
+	if host != domain && !hasDotSuffix(host, domain) {
+		return "", false, errIllegalDomain
+	}
+
+	return domain, false, nil
+}
+
+// cookie returns e with all its attributes
+func (e *entry) cookie() *cookie {
+	c := &cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Path:     e.Path,
+		Domain:   e.Domain,
+		Secure:   e.Secure,
+		HttpOnly: e.HttpOnly,
+		HostOnly: e.HostOnly,
+		SameSite: e.SameSite,
+	}
+
+	if e.Persistent {
+		c.setExpires(e.Expires)
+	}
+
+	return c
+}
+
+func (e *entry) id() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return e.Persistent && !e.Expires.After(now)
+}
+
+func (e *entry) shouldSend(https bool, host, path string) bool {
+	return e.domainMatch(host) && e.pathMatch(path) && (https || !e.Secure)
+}
+
+func (e *entry) domainMatch(host string) bool {
+	if e.Domain == host {
+		return true
+	}
+
+	return !e.HostOnly && hasDotSuffix(host, e.Domain)
+}
+
+func (e *entry) pathMatch(path string) bool {
+	if path == e.Path {
+		return true
+	}
+
+	if !strings.HasPrefix(path, e.Path) {
+		return false
+	}
+
+	return e.Path[len(e.Path)-1] == '/' || path[len(e.Path)] == '/'
+}
+
+func sameSiteString(sameSite http.SameSite) string {
+	switch sameSite {
+	case http.SameSiteLaxMode:
+		return "lax"
+	case http.SameSiteStrictMode:
+		return "strict"
+	case http.SameSiteNoneMode:
+		return "none"
+	}
+
+	return ""
+}
+
+func sameSiteMode(sameSite string) http.SameSite {
+	switch strings.ToLower(sameSite) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+
+	return http.SameSiteDefaultMode
+}
+
+// jarKey returns the eTLD+1 of host, or host itself for IPs and public suffixes
+func jarKey(host string, psl cookiejar.PublicSuffixList) string {
+	if isIP(host) {
+		return host
+	}
+
+	suffix := psl.PublicSuffix(host)
+	if suffix == host {
+		return host
+	}
+
+	i := len(host) - len(suffix)
+	if i <= 0 || host[i-1] != '.' {
+		return host
+	}
+
+	prevDot := strings.LastIndex(host[:i-1], ".")
+	return host[prevDot+1:]
+}
+
+// canonicalHost strips the port and the trailing dot and lowercases host
+func canonicalHost(host string) (string, error) {
+	var err error
+
+	if hasPort(host) {
+		host, _, err = net.SplitHostPort(host)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	host = strings.TrimSuffix(host, ".")
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.ToLower(host), nil
+}
+
+func hasPort(host string) bool {
+	colons := strings.Count(host, ":")
+
+	switch colons {
+	case 0:
+		return false
+	case 1:
+		return true
+	}
+
+	// ipv6
+	return host[0] == '[' && strings.Contains(host, "]:")
+}
+
+func isIP(host string) bool {
+	return net.ParseIP(host) != nil
+}
+
+func hasDotSuffix(s, suffix string) bool {
+	return len(s) > len(suffix) && s[len(s)-len(suffix)-1] == '.' && s[len(s)-len(suffix):] == suffix
+}
+
+// defaultPath is the directory of the request path, RFC 6265 section 5.1.4
+func defaultPath(path string) string {
+	if len(path) == 0 || path[0] != '/' {
+		return "/"
+	}
+
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+
+	return path[:i]
+}
+
+// writeFileAtomic writes data to a temp file then renames it to filename
+func writeFileAtomic(filename string, data []byte) (err error) {
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		debug("ERR(TempFile)", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	_, err = f.Write(data)
+	if err != nil {
+		return
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return
+	}
+
+	err = f.Close()
+	if err != nil {
+		return
+	}
+
+	return os.Rename(f.Name(), filename)
+}
@@ -0,0 +1,285 @@
+package request
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustParseURL(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+
+	return u
+}
+
+// cookieString joins the cookies jar sends to rawURL as "name=value" pairs
+func cookieString(jar http.CookieJar, rawURL string) string {
+	var pairs []string
+
+	for _, c := range jar.Cookies(mustParseURL(rawURL)) {
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+func TestJar(t *testing.T) {
+	jar, err := NewJar(nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	jar.SetCookies(mustParseURL("https://www.example.com/login"), []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".example.com"},
+		{Name: "path", Value: "3", Path: "/cookies/"},
+		{Name: "secure", Value: "4", Secure: true},
+	})
+
+	if s := cookieString(jar, "https://www.example.com/cookies/"); s != "path=3 host=1 domain=2 secure=4" {
+		t.Error(s)
+		return
+	}
+
+	if s := cookieString(jar, "http://www.example.com/"); s != "host=1 domain=2" {
+		t.Error(s)
+		return
+	}
+
+	if s := cookieString(jar, "http://api.example.com/"); s != "domain=2" {
+		t.Error(s)
+		return
+	}
+
+	if s := cookieString(jar, "http://example.org/"); s != "" {
+		t.Error(s)
+		return
+	}
+}
+
+func TestJarPublicSuffix(t *testing.T) {
+	jar, _ := NewJar(nil)
+
+	jar.SetCookies(mustParseURL("http://www.bbc.co.uk/"), []*http.Cookie{
+		{Name: "suffix", Value: "1", Domain: "co.uk"},
+		{Name: "site", Value: "2", Domain: "bbc.co.uk"},
+	})
+
+	if s := cookieString(jar, "http://www.bbc.co.uk/"); s != "site=2" {
+		t.Error(s)
+		return
+	}
+
+	if s := cookieString(jar, "http://www.other.co.uk/"); s != "" {
+		t.Error(s)
+		return
+	}
+}
+
+func TestJarExpire(t *testing.T) {
+	jar, _ := NewJar(nil)
+	u := mustParseURL("http://httpbin.org/")
+
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "one", Value: "1", MaxAge: 1},
+		{Name: "two", Value: "2", Expires: time.Now().Add(time.Hour)},
+		{Name: "three", Value: "3", Expires: time.Now().Add(-time.Hour)},
+	})
+
+	if s := cookieString(jar, u.String()); s != "one=1 two=2" {
+		t.Error(s)
+		return
+	}
+
+	// removed by max-age < 0
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "two", MaxAge: -1},
+	})
+
+	if s := cookieString(jar, u.String()); s != "one=1" {
+		t.Error(s)
+		return
+	}
+
+	if cookies := jar.cookies(u, time.Now().Add(2*time.Second)); len(cookies) != 0 {
+		t.Error(cookies)
+		return
+	}
+
+	// pruned on access
+	if len(jar.entries) != 0 {
+		t.Error(jar.entries)
+		return
+	}
+}
+
+func TestJarPersist(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cookies.json")
+
+	jar, err := NewJar(&JarOption{Filename: filename})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	jar.SetCookies(mustParseURL("https://httpbin.org/"), []*http.Cookie{
+		{Name: "session", Value: "1"},
+		{Name: "persistent", Value: "2", MaxAge: 3600, Secure: true, HttpOnly: true, SameSite: http.SameSiteLaxMode},
+		{Name: "expiring", Value: "3", MaxAge: 1},
+	})
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !strings.Contains(string(data), `"samesite":"lax"`) {
+		t.Error(string(data))
+		return
+	}
+
+	// expire the last cookie on disk
+	jar.mu.Lock()
+	for _, submap := range jar.entries {
+		for id, e := range submap {
+			if e.Name == "expiring" {
+				e.Expires = time.Now().Add(-time.Second)
+				submap[id] = e
+			}
+		}
+	}
+	jar.mu.Unlock()
+
+	err = writeJarFile(jar)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// reload
+	reloaded, err := NewJar(&JarOption{Filename: filename})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if s := cookieString(reloaded, "https://httpbin.org/"); s != "session=1 persistent=2" {
+		t.Error(s)
+		return
+	}
+}
+
+func TestJarMissingFile(t *testing.T) {
+	jar, err := NewJar(&JarOption{Filename: filepath.Join(t.TempDir(), "missing.json")})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(jar.entries) != 0 {
+		t.Error()
+		return
+	}
+}
+
+func TestJarBadFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cookies.json")
+	ioutil.WriteFile(filename, []byte("{"), 0600)
+
+	_, err := NewJar(&JarOption{Filename: filename})
+	if err == nil {
+		t.Error()
+		return
+	}
+}
+
+func TestNewWithJar(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "one", Value: "1", Path: "/", MaxAge: 60})
+	}))
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "cookies.json")
+	jar, _ := NewJar(&JarOption{Filename: filename})
+
+	client := NewWithJar(jar)
+
+	_, _, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// a new client picks the cookie from the file
+	reloaded, _ := NewJar(&JarOption{Filename: filename})
+
+	value, err := NewWithJar(reloaded).GetCookie(ts.URL, "one")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if value != "1" {
+		t.Error(value)
+		return
+	}
+}
+
+// writeJarFile saves jar without pruning
+func writeJarFile(jar *Jar) error {
+	jar.mu.Lock()
+	entries := jar.sorted()
+	jar.mu.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(jar.filename, data)
+}
+
+func TestJarClearSave(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cookies.json")
+	jar, _ := NewJar(&JarOption{Filename: filename})
+
+	jar.SetCookies(mustParseURL("http://example.com/"), []*http.Cookie{
+		{Name: "one", Value: "1"},
+		{Name: "two", Value: "2"},
+	})
+
+	if jar.Delete("example.com", "one") != 1 {
+		t.Error()
+		return
+	}
+
+	reloaded, _ := NewJar(&JarOption{Filename: filename})
+	if s := cookieString(reloaded, "http://example.com/"); s != "two=2" {
+		t.Error(s)
+		return
+	}
+
+	if jar.Clear() != 1 {
+		t.Error()
+		return
+	}
+
+	reloaded, _ = NewJar(&JarOption{Filename: filename})
+	if len(reloaded.All()) != 0 {
+		t.Error()
+		return
+	}
+}
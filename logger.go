@@ -0,0 +1,471 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogKind is the kind of a #LogEvent
+type LogKind string
+
+// log kinds
+const (
+	LogRequest  LogKind = "request"  // an attempt is sent
+	LogResponse LogKind = "response" // the response body of an attempt is closed
+	LogError    LogKind = "error"    // an attempt failed without response
+	LogRetry    LogKind = "retry"    // an attempt is going to be retried
+	LogRedirect LogKind = "redirect" // a redirect is followed
+)
+
+// Redacted replaces the secrets in the logs
+const Redacted = "[REDACTED]"
+
+// DefaultRedactHeaders are the headers redacted by default
+var DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// LogEvent is a structured client event
+type LogEvent struct {
+	Kind       LogKind
+	Method     string
+	URL        string // without password and with the redacted query fields
+	StatusCode int
+	Duration   time.Duration // since the attempt was sent
+	Bytes      int64         // request body size for LogRequest, response body bytes read for LogResponse
+	Attempt    int
+	Header     http.Header   // with LogOption.Header, redacted
+	Body       string        // with LogOption.Body, redacted, up to MaxSnippetSize bytes
+	Location   string        // LogRedirect target
+	Wait       time.Duration // LogRetry backoff
+	Err        error
+}
+
+// Logger receives the client events
+type Logger interface {
+	Log(ctx context.Context, event *LogEvent)
+}
+
+// LoggerFunc is a func #Logger
+type LoggerFunc func(ctx context.Context, event *LogEvent)
+
+// Log calls f
+func (f LoggerFunc) Log(ctx context.Context, event *LogEvent) {
+	f(ctx, event)
+}
+
+// LogOption holds the #SetLogger settings
+type LogOption struct {
+	Header bool // log the request and response headers
+	Body   bool // log the beginning of the request and response bodies
+
+	RedactHeaders []string // default: DefaultRedactHeaders
+	RedactFields  []string // query params, json and form body fields, like "password", any json value is redacted
+}
+
+// clientLogger is a #Logger with its redaction rules
+type clientLogger struct {
+	logger  Logger
+	header  bool
+	body    bool
+	headers []string
+	json    *regexp.Regexp // "field": , followed by any json value
+	form    *regexp.Regexp // field=value
+}
+
+// SetLogger sets client logger, opt can be nil
+// nil logger turns the logs off
+func (c *Client) SetLogger(logger Logger, opt *LogOption) {
+	debug()
+
+	if logger == nil {
+		c.logger = nil
+		return
+	}
+
+	if opt == nil {
+		opt = &LogOption{}
+	}
+
+	l := &clientLogger{
+		logger:  logger,
+		header:  opt.Header,
+		body:    opt.Body,
+		headers: opt.RedactHeaders,
+	}
+
+	if l.headers == nil {
+		l.headers = DefaultRedactHeaders
+	}
+
+	if len(opt.RedactFields) > 0 {
+		fields := make([]string, len(opt.RedactFields))
+		for i, field := range opt.RedactFields {
+			fields[i] = regexp.QuoteMeta(field)
+		}
+
+		names := strings.Join(fields, "|")
+
+		l.json = regexp.MustCompile(`"(?:` + names + `)"\s*:\s*`)
+		l.form = regexp.MustCompile(`((?:^|&)(?:` + names + `)=)[^&]*`)
+	}
+
+	c.logger = l
+}
+
+// log sends event to the client logger if any
+func (c *Client) log(ctx context.Context, event *LogEvent) {
+	if c.logger == nil {
+		return
+	}
+
+	c.logger.logger.Log(ctx, event)
+}
+
+// logRequest logs req before it is sent
+func (c *Client) logRequest(req *http.Request) {
+	if c.logger == nil {
+		return
+	}
+
+	event := c.logger.event(LogRequest, req)
+	event.Bytes = req.ContentLength
+
+	if c.logger.header {
+		event.Header = c.logger.redactHeader(req.Header)
+	}
+
+	if c.logger.body && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err == nil {
+			snippet, _ := ioutil.ReadAll(io.LimitReader(body, MaxSnippetSize))
+			body.Close()
+
+			event.Body = c.logger.redactBody(snippet)
+		}
+	}
+
+	c.log(req.Context(), event)
+}
+
+// logResponse makes res log itself when its body is closed
+// with the method and url of the last request, after redirects
+func (c *Client) logResponse(req *http.Request, res *http.Response, start time.Time) {
+	if c.logger == nil {
+		return
+	}
+
+	body := &countBody{
+		rc: res.Body,
+		done: func(n int64, snippet []byte) {
+			event := c.logger.event(LogResponse, res.Request)
+			event.StatusCode = res.StatusCode
+			event.Duration = time.Now().Sub(start)
+			event.Bytes = n
+
+			if c.logger.header {
+				event.Header = c.logger.redactHeader(res.Header)
+			}
+
+			if c.logger.body {
+				event.Body = c.logger.redactBody(snippet)
+			}
+
+			c.log(req.Context(), event)
+		},
+	}
+
+	if c.logger.body {
+		body.snippet = &bytes.Buffer{}
+	}
+
+	res.Body = body
+}
+
+// logError logs the error of req
+func (c *Client) logError(req *http.Request, err error, start time.Time) {
+	if c.logger == nil {
+		return
+	}
+
+	event := c.logger.event(LogError, req)
+	event.Duration = time.Now().Sub(start)
+	event.Err = err
+
+	c.log(req.Context(), event)
+}
+
+// logRetry logs the retry of req after a statusCode response or err
+func (c *Client) logRetry(req *http.Request, statusCode int, err error, wait time.Duration) {
+	if c.logger == nil {
+		return
+	}
+
+	event := c.logger.event(LogRetry, req)
+	event.StatusCode = statusCode
+	event.Wait = wait
+	event.Err = err
+
+	c.log(req.Context(), event)
+}
+
+// logRedirect logs the redirect to req
+func (c *Client) logRedirect(req *http.Request, via []*http.Request) {
+	if c.logger == nil {
+		return
+	}
+
+	event := c.logger.event(LogRedirect, via[len(via)-1])
+	event.StatusCode = req.Response.StatusCode
+	event.Location = c.logger.redactURL(req)
+
+	c.log(req.Context(), event)
+}
+
+func (l *clientLogger) event(kind LogKind, req *http.Request) *LogEvent {
+	attempt, ok := req.Context().Value(attemptKey{}).(int)
+	if !ok {
+		attempt = 1
+	}
+
+	return &LogEvent{
+		Kind:    kind,
+		Method:  req.Method,
+		URL:     l.redactURL(req),
+		Attempt: attempt,
+	}
+}
+
+func (l *clientLogger) redactURL(req *http.Request) string {
+	u := *req.URL
+
+	if l.form != nil {
+		u.RawQuery = l.form.ReplaceAllString(u.RawQuery, "${1}"+Redacted)
+	}
+
+	return u.Redacted()
+}
+
+func (l *clientLogger) redactHeader(header http.Header) http.Header {
+	header = header.Clone()
+
+	for _, key := range l.headers {
+		if header.Get(key) != "" {
+			header.Set(key, Redacted)
+		}
+	}
+
+	return header
+}
+
+func (l *clientLogger) redactBody(body []byte) string {
+	if l.json == nil {
+		return string(body)
+	}
+
+	body = l.redactJSON(body)
+	body = l.form.ReplaceAll(body, []byte("${1}"+Redacted))
+
+	return string(body)
+}
+
+// redactJSON replaces the values of the json fields, nested objects and arrays included
+func (l *clientLogger) redactJSON(body []byte) []byte {
+	var redacted []byte
+	last := 0
+
+	for _, loc := range l.json.FindAllIndex(body, -1) {
+		// in a value already redacted
+		if loc[0] < last {
+			continue
+		}
+
+		redacted = append(redacted, body[last:loc[1]]...)
+		redacted = append(redacted, `"`+Redacted+`"`...)
+
+		last = jsonValueEnd(body, loc[1])
+	}
+
+	if redacted == nil {
+		return body
+	}
+
+	return append(redacted, body[last:]...)
+}
+
+// jsonValueEnd returns the end of the json value starting at i in data,
+// len(data) if it is cut
+func jsonValueEnd(data []byte, i int) int {
+	depth := 0
+	inString := false
+
+	for ; i < len(data); i++ {
+		c := data[i]
+
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+
+				if depth == 0 {
+					return i + 1
+				}
+			}
+
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+
+		case '{', '[':
+			depth++
+
+		case '}', ']':
+			// the end of the parent
+			if depth == 0 {
+				return i
+			}
+
+			depth--
+
+			if depth == 0 {
+				return i + 1
+			}
+
+		case ',', ' ', '\t', '\r', '\n':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return len(data)
+}
+
+// countBody counts the bytes read from rc, keeps the first MaxSnippetSize ones in snippet if any
+// and calls done once on Close
+type countBody struct {
+	rc      io.ReadCloser
+	n       int64
+	snippet *bytes.Buffer
+	done    func(n int64, snippet []byte)
+	once    sync.Once
+}
+
+func (b *countBody) Read(p []byte) (n int, err error) {
+	n, err = b.rc.Read(p)
+	atomic.AddInt64(&b.n, int64(n))
+
+	if b.snippet != nil && b.snippet.Len() < MaxSnippetSize {
+		left := MaxSnippetSize - b.snippet.Len()
+		if left > n {
+			left = n
+		}
+
+		b.snippet.Write(p[:left])
+	}
+
+	return
+}
+
+// count returns the number of bytes read so far
+func (b *countBody) count() int64 {
+	return atomic.LoadInt64(&b.n)
+}
+
+func (b *countBody) Close() error {
+	err := b.rc.Close()
+
+	b.once.Do(func() {
+		var snippet []byte
+		if b.snippet != nil {
+			snippet = b.snippet.Bytes()
+		}
+
+		b.done(b.count(), snippet)
+	})
+
+	return err
+}
+
+// slogLogger writes the events to a *slog.Logger
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger returns a #Logger that writes the events to logger
+// at debug level for requests and redirects, info for responses,
+// warn for retries and error for errors
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return &slogLogger{logger: logger}
+}
+
+func (s *slogLogger) Log(ctx context.Context, event *LogEvent) {
+	level := slog.LevelInfo
+
+	switch event.Kind {
+	case LogRequest, LogRedirect:
+		level = slog.LevelDebug
+
+	case LogRetry:
+		level = slog.LevelWarn
+
+	case LogError:
+		level = slog.LevelError
+	}
+
+	if !s.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", event.Method),
+		slog.String("url", event.URL),
+		slog.Int("attempt", event.Attempt),
+	}
+
+	if event.StatusCode != 0 {
+		attrs = append(attrs, slog.Int("status", event.StatusCode))
+	}
+
+	if event.Duration != 0 {
+		attrs = append(attrs, slog.Duration("duration", event.Duration))
+	}
+
+	if event.Kind == LogRequest || event.Kind == LogResponse {
+		attrs = append(attrs, slog.Int64("bytes", event.Bytes))
+	}
+
+	if event.Location != "" {
+		attrs = append(attrs, slog.String("location", event.Location))
+	}
+
+	if event.Wait != 0 {
+		attrs = append(attrs, slog.Duration("wait", event.Wait))
+	}
+
+	if event.Header != nil {
+		attrs = append(attrs, slog.Any("header", event.Header))
+	}
+
+	if event.Body != "" {
+		attrs = append(attrs, slog.String("body", event.Body))
+	}
+
+	if event.Err != nil {
+		attrs = append(attrs, slog.String("error", event.Err.Error()))
+	}
+
+	s.logger.LogAttrs(ctx, level, "request "+string(event.Kind), attrs...)
+}
@@ -0,0 +1,277 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// eventRecorder is a #Logger that keeps the events
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []*LogEvent
+}
+
+func (r *eventRecorder) Log(ctx context.Context, event *LogEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+}
+
+func (r *eventRecorder) kinds() (kinds []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, event := range r.events {
+		kinds = append(kinds, string(event.Kind))
+	}
+
+	return
+}
+
+func newLoginServer() *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/done?token=abc&next=home", http.StatusSeeOther)
+	})
+
+	mux.HandleFunc("/done", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"user":"ddo","token":"s3cr\"et"}`))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestLogger(t *testing.T) {
+	ts := newLoginServer()
+	defer ts.Close()
+
+	recorder := &eventRecorder{}
+
+	client := New()
+	client.SetLogger(recorder, &LogOption{
+		Header:       true,
+		Body:         true,
+		RedactFields: []string{"password", "token"},
+	})
+
+	_, _, err := client.Request(&Option{
+		URL:    ts.URL + "/login",
+		Method: "POST",
+		Query: &Data{
+			"password": []string{"123"},
+		},
+		JSON: map[string]string{
+			"user":     "ddo",
+			"password": "123",
+		},
+		Header: &Header{
+			"Authorization": "Bearer abc",
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if strings.Join(recorder.kinds(), ",") != "request,redirect,response" {
+		t.Error(recorder.kinds())
+		return
+	}
+
+	req, redirect, res := recorder.events[0], recorder.events[1], recorder.events[2]
+
+	if req.Method != "POST" || req.URL != ts.URL+"/login?password="+Redacted || req.Attempt != 1 {
+		t.Error(req)
+		return
+	}
+
+	if req.Header.Get("Authorization") != Redacted || req.Bytes != int64(len(`{"password":"123","user":"ddo"}`)) {
+		t.Error(req.Header, req.Bytes)
+		return
+	}
+
+	if req.Body != `{"password":"`+Redacted+`","user":"ddo"}` {
+		t.Error(req.Body)
+		return
+	}
+
+	if redirect.StatusCode != http.StatusSeeOther || redirect.Location != ts.URL+"/done?token="+Redacted+"&next=home" {
+		t.Error(redirect)
+		return
+	}
+
+	if res.StatusCode != 200 || res.Method != "GET" || res.Duration <= 0 || res.Bytes != int64(len(`{"user":"ddo","token":"s3cr\"et"}`)) {
+		t.Error(res)
+		return
+	}
+
+	if res.Header.Get("Set-Cookie") != Redacted || res.Body != `{"user":"ddo","token":"`+Redacted+`"}` {
+		t.Error(res.Header, res.Body)
+		return
+	}
+}
+
+func TestLoggerRetry(t *testing.T) {
+	ts, _ := newFlakyServer(1, http.StatusBadGateway, nil)
+	defer ts.Close()
+
+	recorder := &eventRecorder{}
+
+	client := New()
+	client.SetRetry(newTestRetryPolicy())
+	client.SetLogger(recorder, nil)
+
+	_, _, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if strings.Join(recorder.kinds(), ",") != "request,response,retry,request,response" {
+		t.Error(recorder.kinds())
+		return
+	}
+
+	retry := recorder.events[2]
+	if retry.StatusCode != http.StatusBadGateway || retry.Attempt != 1 || retry.Wait <= 0 {
+		t.Error(retry)
+		return
+	}
+
+	if recorder.events[3].Attempt != 2 || recorder.events[4].StatusCode != 200 {
+		t.Error(recorder.events[3], recorder.events[4])
+		return
+	}
+
+	// without LogOption
+	if recorder.events[0].Header != nil || recorder.events[4].Body != "" {
+		t.Error(recorder.events[0])
+		return
+	}
+}
+
+func TestLoggerError(t *testing.T) {
+	ts := httptest.NewServer(nil)
+	ts.Close()
+
+	recorder := &eventRecorder{}
+
+	client := New()
+	client.SetLogger(recorder, nil)
+
+	client.Request(&Option{
+		URL: ts.URL,
+	})
+
+	if strings.Join(recorder.kinds(), ",") != "request,error" || recorder.events[1].Err == nil {
+		t.Error(recorder.kinds())
+		return
+	}
+
+	// off
+	client.SetLogger(nil, nil)
+
+	client.Request(&Option{
+		URL: ts.URL,
+	})
+
+	if len(recorder.kinds()) != 2 {
+		t.Error(recorder.kinds())
+		return
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	ts := newEchoServer()
+	defer ts.Close()
+
+	var buf bytes.Buffer
+
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	client := New()
+	client.SetLogger(NewSlogLogger(logger), nil)
+
+	_, _, err := client.Request(&Option{
+		URL: ts.URL,
+		Header: &Header{
+			"X-Echo": "one",
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Error(lines)
+		return
+	}
+
+	var record struct {
+		Level   string `json:"level"`
+		Msg     string `json:"msg"`
+		Method  string `json:"method"`
+		URL     string `json:"url"`
+		Status  int    `json:"status"`
+		Bytes   int64  `json:"bytes"`
+		Attempt int    `json:"attempt"`
+	}
+
+	err = json.Unmarshal([]byte(lines[1]), &record)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if record.Level != "INFO" || record.Msg != "request response" || record.Method != "GET" || record.URL != ts.URL {
+		t.Error(record)
+		return
+	}
+
+	if record.Status != 200 || record.Bytes != 3 || record.Attempt != 1 {
+		t.Error(record)
+		return
+	}
+}
+
+func TestLoggerRedactJSON(t *testing.T) {
+	client := New()
+	client.SetLogger(&eventRecorder{}, &LogOption{
+		RedactFields: []string{"password", "token"},
+	})
+
+	r := `"` + Redacted + `"`
+
+	cases := map[string]string{
+		`{"password":1234,"user":"ddo"}`:                  `{"password":` + r + `,"user":"ddo"}`,
+		`{"password": true}`:                              `{"password": ` + r + `}`,
+		`{"password":null, "token":-1.5e3}`:               `{"password":` + r + `, "token":` + r + `}`,
+		`{"token":{"password":"a","b":[1,"}"]},"user":1}`: `{"token":` + r + `,"user":1}`,
+		`[{"token":["a","b"]},{"password":"x\"y"}]`:       `[{"token":` + r + `},{"password":` + r + `}]`,
+		`{"user":"ddo","token":{"cut":`:                   `{"user":"ddo","token":` + r,
+		`{"user":"password"}`:                             `{"user":"password"}`,
+	}
+
+	for body, expected := range cases {
+		redacted := client.logger.redactBody([]byte(body))
+		if redacted != expected {
+			t.Error(body, redacted)
+			return
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// File is a multipart/form-data file part
+// the content is read from Path, Reader or Bytes, first one set wins
+type File struct {
+	Field       string // required
+	Name        string // default: base name of Path
+	ContentType string // default: "application/octet-stream"
+	Path        string
+	Reader      io.Reader // can not be replayed on retry
+	Bytes       []byte
+}
+
+// ErrFileField is returned when a #File has no Field
+var ErrFileField = errors.New("request: file field is required")
+
+// makeMultipart returns the multipart body of opt.Files and opt.Form fields
+// files at Path are checked right away so missing files fail before the request is sent
+// but only opened when the body is read, a body never sent holds nothing
+func makeMultipart(opt *Option, boundary string) (body io.ReadCloser, err error) {
+	err = checkFiles(opt.Files)
+	if err != nil {
+		debug("ERR(multipart)", err)
+		return
+	}
+
+	body = &multipartBody{opt: opt, boundary: boundary}
+	return
+}
+
+// checkFiles returns an error for a file without Field or a missing Path
+func checkFiles(files []*File) error {
+	for _, file := range files {
+		if file.Field == "" {
+			return ErrFileField
+		}
+
+		if file.Path == "" {
+			continue
+		}
+
+		_, err := os.Stat(file.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// multipartBody streams the multipart body
+// the files are opened and the writer started on the first read
+type multipartBody struct {
+	opt      *Option
+	boundary string
+
+	once sync.Once
+	pr   *io.PipeReader // nil if closed before the first read
+}
+
+func (b *multipartBody) start() {
+	readers, opened, err := openFiles(b.opt.Files)
+
+	pr, pw := io.Pipe()
+	b.pr = pr
+
+	if err != nil {
+		debug("ERR(multipart)", err)
+		pw.CloseWithError(err)
+		return
+	}
+
+	go func() {
+		defer closeAll(opened)
+
+		pw.CloseWithError(writeMultipart(pw, b.boundary, b.opt, readers))
+	}()
+}
+
+func (b *multipartBody) Read(p []byte) (n int, err error) {
+	b.once.Do(b.start)
+
+	if b.pr == nil {
+		return 0, io.ErrClosedPipe
+	}
+
+	return b.pr.Read(p)
+}
+
+// Close stops the writer and closes the files, if started
+func (b *multipartBody) Close() error {
+	// not started, nothing to release
+	b.once.Do(func() {})
+
+	if b.pr == nil {
+		return nil
+	}
+
+	return b.pr.Close()
+}
+
+// openFiles returns the content reader of each file
+// opened holds the files opened here, to be closed by the caller
+func openFiles(files []*File) (readers []io.Reader, opened []*os.File, err error) {
+	for _, file := range files {
+		if file.Field == "" {
+			err = ErrFileField
+			break
+		}
+
+		switch {
+		case file.Path != "":
+			f, openErr := os.Open(file.Path)
+			if openErr != nil {
+				err = openErr
+				break
+			}
+
+			opened = append(opened, f)
+			readers = append(readers, f)
+
+		case file.Reader != nil:
+			readers = append(readers, file.Reader)
+
+		default:
+			readers = append(readers, bytes.NewReader(file.Bytes))
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		closeAll(opened)
+		return nil, nil, err
+	}
+
+	return
+}
+
+func writeMultipart(w io.Writer, boundary string, opt *Option, readers []io.Reader) (err error) {
+	mw := multipart.NewWriter(w)
+
+	err = mw.SetBoundary(boundary)
+	if err != nil {
+		return
+	}
+
+	if opt.Form != nil {
+		for key, slice := range *opt.Form {
+			for _, value := range slice {
+				err = mw.WriteField(key, value)
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	for i, file := range opt.Files {
+		part, err := mw.CreatePart(fileHeader(file))
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(part, readers[i])
+		if err != nil {
+			debug("ERR(multipart)", err)
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+func fileHeader(file *File) textproto.MIMEHeader {
+	name := file.Name
+	if name == "" && file.Path != "" {
+		name = filepath.Base(file.Path)
+	}
+
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="`+escapeQuotes(file.Field)+`"; filename="`+escapeQuotes(name)+`"`)
+	header.Set("Content-Type", contentType)
+	return header
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// replayableFiles reports whether the multipart body can be built again
+func replayableFiles(files []*File) bool {
+	for _, file := range files {
+		if file.Path == "" && file.Reader != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+func closeAll(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
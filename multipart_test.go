@@ -0,0 +1,236 @@
+package request
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+type multipartRes struct {
+	Fields       map[string][]string `json:"fields"`
+	Files        map[string]string   `json:"files"`
+	Names        map[string]string   `json:"names"`
+	ContentTypes map[string]string   `json:"content_types"`
+}
+
+// newMultipartServer returns a local server that echoes the multipart form as json
+func newMultipartServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseMultipartForm(1 << 20)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		res := multipartRes{
+			Fields:       r.MultipartForm.Value,
+			Files:        map[string]string{},
+			Names:        map[string]string{},
+			ContentTypes: map[string]string{},
+		}
+
+		for field, headers := range r.MultipartForm.File {
+			f, _ := headers[0].Open()
+			data, _ := ioutil.ReadAll(f)
+			f.Close()
+
+			res.Files[field] = string(data)
+			res.Names[field] = headers[0].Filename
+			res.ContentTypes[field] = headers[0].Header.Get("Content-Type")
+		}
+
+		json.NewEncoder(w).Encode(res)
+	}))
+}
+
+func TestMultipart(t *testing.T) {
+	ts := newMultipartServer()
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "one.txt")
+	if err := ioutil.WriteFile(path, []byte("mot"), 0600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	client := New()
+
+	data, res, err := client.Request(&Option{
+		URL:    ts.URL,
+		Method: "POST",
+		Form: &Data{
+			"email": []string{"ddo@ddo.me"},
+			"three": []string{"3", "ba", "trois"},
+		},
+		Files: []*File{
+			{Field: "one", Path: path},
+			{Field: "two", Name: "two.json", ContentType: "application/json", Reader: strings.NewReader(`{"two":2}`)},
+			{Field: "three", Name: "three.bin", Bytes: []byte("ba")},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.StatusCode != 200 {
+		t.Error(res.StatusCode)
+		return
+	}
+
+	var testData multipartRes
+	json.Unmarshal(data, &testData)
+
+	if testData.Fields["email"][0] != "ddo@ddo.me" {
+		t.Error()
+		return
+	}
+
+	if len(testData.Fields["three"]) != 3 {
+		t.Error()
+		return
+	}
+
+	if testData.Files["one"] != "mot" || testData.Names["one"] != "one.txt" {
+		t.Error()
+		return
+	}
+
+	if testData.ContentTypes["one"] != "application/octet-stream" {
+		t.Error()
+		return
+	}
+
+	if testData.Files["two"] != `{"two":2}` || testData.ContentTypes["two"] != "application/json" {
+		t.Error()
+		return
+	}
+
+	if testData.Files["three"] != "ba" || testData.Names["three"] != "three.bin" {
+		t.Error()
+		return
+	}
+}
+
+func TestMultipartHeader(t *testing.T) {
+	req, err := newRequest(context.Background(), &Option{
+		URL:    "http://127.0.0.1",
+		Method: "POST",
+		Form:   &Data{},
+		Files: []*File{
+			{Field: "one", Bytes: []byte("1")},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer req.Body.Close()
+
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data; boundary=") {
+		t.Error(req.Header.Get("Content-Type"))
+		return
+	}
+
+	// bytes can be replayed
+	if req.GetBody == nil {
+		t.Error()
+		return
+	}
+}
+
+func TestMultipartMissingFile(t *testing.T) {
+	client := New()
+
+	_, _, err := client.Request(&Option{
+		URL:    "http://127.0.0.1:1",
+		Method: "POST",
+		Files: []*File{
+			{Field: "one", Path: filepath.Join(t.TempDir(), "missing")},
+		},
+	})
+	if !os.IsNotExist(err) {
+		t.Error(err)
+		return
+	}
+}
+
+func TestMultipartRetry(t *testing.T) {
+	ts, _ := newFlakyServer(1, http.StatusBadGateway, nil)
+	defer ts.Close()
+
+	policy := newTestRetryPolicy()
+	policy.Methods = append(policy.Methods, "POST")
+	policy.MaxBackoff = time.Millisecond
+
+	client := New()
+	client.SetRetry(policy)
+
+	data, res, err := client.Request(&Option{
+		URL:    ts.URL,
+		Method: "POST",
+		Files: []*File{
+			{Field: "one", Bytes: []byte("mot")},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if Attempts(res) != 2 {
+		t.Error(Attempts(res))
+		return
+	}
+
+	if !strings.Contains(string(data), "mot") {
+		t.Error(string(data))
+		return
+	}
+}
+
+func TestMultipartNotSent(t *testing.T) {
+	ts := httptest.NewServer(nil)
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "upload.txt")
+	ioutil.WriteFile(path, []byte("data"), 0600)
+
+	errAbort := errors.New("abort")
+
+	client := New()
+	client.BeforeRequest(func(req *http.Request) error {
+		return errAbort
+	})
+
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 50; i++ {
+		_, _, err := client.Request(&Option{
+			URL:    ts.URL,
+			Method: "POST",
+			Files: []*File{
+				{Field: "file", Path: path},
+			},
+		})
+		if !errors.Is(err, errAbort) {
+			t.Error(err)
+			return
+		}
+	}
+
+	// no writer left blocked
+	if leaked := runtime.NumGoroutine() - before; leaked > 5 {
+		t.Error(leaked)
+		return
+	}
+}
@@ -0,0 +1,287 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTokenSkew is how long before its expiry a token is refreshed
+	DefaultTokenSkew = 30 * time.Second
+
+	// DefaultTokenTimeout bounds a token request, it does not end with the request waiting for it
+	DefaultTokenTimeout = 30 * time.Second
+)
+
+// ErrNoAccessToken is returned when the token endpoint answers without access token
+var ErrNoAccessToken = errors.New("request: no access token")
+
+// TokenError is returned for a failed token request
+type TokenError struct {
+	StatusCode  int
+	Code        string // error, like "invalid_client"
+	Description string // error_description
+}
+
+// invalidGrant returns true if the server rejected the grant itself, like a dead refresh token
+func (e *TokenError) invalidGrant() bool {
+	return (e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnauthorized) && e.Code == "invalid_grant"
+}
+
+func (e *TokenError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("request: token %d: %s: %s", e.StatusCode, e.Code, e.Description)
+	}
+
+	return fmt.Sprintf("request: token %d: %s", e.StatusCode, e.Code)
+}
+
+// Token is an OAuth2 token
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresIn    int64     `json:"expires_in"` // seconds, as received
+	Expiry       time.Time `json:"-"`          // zero if it does not expire
+}
+
+// valid returns true if t can be used for skew more
+func (t *Token) valid(skew time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+
+	return t.Expiry.IsZero() || time.Now().Add(skew).Before(t.Expiry)
+}
+
+type tokenError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// noAuth is an #Authenticator that sends the request as is
+type noAuth struct{}
+
+func (noAuth) Authenticate(req *http.Request) error {
+	return nil
+}
+
+// OAuth2 is a #Challenger that authorizes the requests with an OAuth2 bearer token
+// the token is fetched with the client-credentials grant, or the refresh-token grant
+// once a refresh token is known, then cached until Skew before its expiry
+// a refresh token rejected with invalid_grant is dropped for the client-credentials grant
+// concurrent requests share the same token request, it goes on if one of them is canceled
+// a 401 response is sent once more with a fresh token
+type OAuth2 struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RefreshToken string        // use the refresh-token grant from the start
+	Skew         time.Duration // default: DefaultTokenSkew
+	AuthInForm   bool          // send the client id and secret as form fields instead of basic auth
+	Client       *Client       // default: a #Client created on the first token request
+
+	mu      sync.Mutex
+	client  *Client
+	token   *Token
+	refresh string
+	call    *tokenCall
+}
+
+// tokenCall is an in-flight token request
+type tokenCall struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// Authenticate implements #Authenticator
+func (a *OAuth2) Authenticate(req *http.Request) error {
+	token, err := a.Token(req.Context())
+	if err != nil {
+		return err
+	}
+
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+
+	req.Header.Set("Authorization", tokenType+" "+token.AccessToken)
+	return nil
+}
+
+// Challenge implements #Challenger
+// it drops the cached token if it is the one req was sent with
+func (a *OAuth2) Challenge(req *http.Request, res *http.Response) (retry bool, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != nil && strings.HasSuffix(req.Header.Get("Authorization"), " "+a.token.AccessToken) {
+		debug("EXPIRED")
+		a.token = nil
+	}
+
+	return true, nil
+}
+
+// Token returns the cached token, or a new one if it is about to expire
+func (a *OAuth2) Token(ctx context.Context) (token *Token, err error) {
+	skew := a.Skew
+	if skew == 0 {
+		skew = DefaultTokenSkew
+	}
+
+	a.mu.Lock()
+
+	if a.token.valid(skew) {
+		token = a.token
+		a.mu.Unlock()
+		return
+	}
+
+	call := a.call
+
+	// none in flight
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		a.call = call
+
+		if a.client == nil {
+			a.client = a.Client
+			if a.client == nil {
+				a.client = New()
+			}
+		}
+
+		refresh := a.refresh
+		if refresh == "" {
+			refresh = a.RefreshToken
+		}
+
+		// detached from the caller cancellation, the other callers wait for it too
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DefaultTokenTimeout)
+
+		go func() {
+			defer cancel()
+			a.run(fetchCtx, call, a.client, refresh)
+		}()
+	}
+
+	a.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return nil, ctxError(ctx, ctx.Err())
+	}
+}
+
+// run fetches the token of call and caches it
+func (a *OAuth2) run(ctx context.Context, call *tokenCall, client *Client, refresh string) {
+	call.token, call.err = a.fetch(ctx, client, refresh)
+
+	var tokenErr *TokenError
+
+	// a dead refresh token, kept on any other error like a 503
+	deadRefresh := refresh != "" && errors.As(call.err, &tokenErr) && tokenErr.invalidGrant()
+
+	// back to the client-credentials grant, unless the refresh-token grant is the only one
+	if deadRefresh && a.RefreshToken == "" {
+		debug("ERR(refresh)", call.err)
+		call.token, call.err = a.fetch(ctx, client, "")
+	}
+
+	a.mu.Lock()
+	a.call = nil
+
+	if deadRefresh {
+		a.refresh = ""
+	}
+
+	if call.err == nil {
+		a.token = call.token
+
+		if call.token.RefreshToken != "" {
+			a.refresh = call.token.RefreshToken
+		}
+	}
+
+	a.mu.Unlock()
+	close(call.done)
+}
+
+// fetch posts a token request to TokenURL
+func (a *OAuth2) fetch(ctx context.Context, client *Client, refresh string) (token *Token, err error) {
+	form := Data{}
+
+	if refresh != "" {
+		form["grant_type"] = []string{"refresh_token"}
+		form["refresh_token"] = []string{refresh}
+	} else {
+		form["grant_type"] = []string{"client_credentials"}
+	}
+
+	if len(a.Scopes) > 0 {
+		form["scope"] = []string{strings.Join(a.Scopes, " ")}
+	}
+
+	opt := &Option{
+		URL:          a.TokenURL,
+		Method:       "POST",
+		Form:         &form,
+		Context:      ctx,
+		AcceptStatus: []StatusRange{StatusAny},
+	}
+
+	if a.AuthInForm {
+		form["client_id"] = []string{a.ClientID}
+		form["client_secret"] = []string{a.ClientSecret}
+
+		// not the client default authenticator, it can be this OAuth2
+		opt.Auth = noAuth{}
+	} else {
+		opt.Auth = &BasicAuth{Username: a.ClientID, Password: a.ClientSecret}
+	}
+
+	token = &Token{}
+	tokenErr := &tokenError{}
+
+	opt.Result = token
+	opt.ErrorResult = tokenErr
+
+	debug("TOKEN", form["grant_type"])
+
+	start := time.Now()
+
+	_, res, err := client.RequestContext(ctx, opt)
+	if err != nil {
+		debug("ERR(token)", err)
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &TokenError{
+			StatusCode:  res.StatusCode,
+			Code:        tokenErr.Code,
+			Description: tokenErr.Description,
+		}
+	}
+
+	if token.AccessToken == "" {
+		return nil, ErrNoAccessToken
+	}
+
+	if token.ExpiresIn > 0 {
+		token.Expiry = start.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return
+}
@@ -0,0 +1,484 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer is a local OAuth2 token endpoint for the client app:secret
+// and an api at /api that only accepts its last token
+type tokenServer struct {
+	*httptest.Server
+
+	expiresIn     int
+	delay         time.Duration
+	refreshStatus int // the error status of the refresh-token grant, 0 if none
+
+	mu       sync.Mutex
+	count    int
+	grants   []string
+	scope    string
+	apiCalls int32
+}
+
+func newTokenServer(expiresIn int) *tokenServer {
+	s := &tokenServer{expiresIn: expiresIn}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", s.serveToken)
+	mux.HandleFunc("/api", s.serveAPI)
+
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+func (s *tokenServer) serveToken(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(s.delay)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+
+	if id != "app" || secret != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid_client","error_description":"bad secret"}`))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grant := r.PostFormValue("grant_type")
+	if grant == "refresh_token" {
+		grant += ":" + r.PostFormValue("refresh_token")
+	}
+
+	s.grants = append(s.grants, grant)
+
+	if s.refreshStatus != 0 && r.PostFormValue("grant_type") == "refresh_token" {
+		w.WriteHeader(s.refreshStatus)
+
+		if s.refreshStatus == http.StatusBadRequest {
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+		} else {
+			w.Write([]byte(`{"error":"temporarily_unavailable"}`))
+		}
+		return
+	}
+
+	s.count++
+	s.scope = r.PostFormValue("scope")
+
+	fmt.Fprintf(w, `{"access_token":"t%d","token_type":"bearer","refresh_token":"r%d","expires_in":%d}`, s.count, s.count, s.expiresIn)
+}
+
+func (s *tokenServer) serveAPI(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&s.apiCalls, 1)
+
+	s.mu.Lock()
+	expected := fmt.Sprintf("Bearer t%d", s.count)
+	s.mu.Unlock()
+
+	if r.Header.Get("Authorization") != expected {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	w.Write([]byte("ok"))
+}
+
+// revoke makes the api reject the current token
+func (s *tokenServer) revoke() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.count++
+}
+
+func TestOAuth2(t *testing.T) {
+	ts := newTokenServer(3600)
+	defer ts.Close()
+
+	auth := &OAuth2{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "app",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+	}
+
+	client := New()
+	client.SetAuth(auth)
+
+	for i := 0; i < 3; i++ {
+		data, _, err := client.Request(&Option{
+			URL: ts.URL + "/api",
+		})
+		if err != nil || string(data) != "ok" {
+			t.Error(err, string(data))
+			return
+		}
+	}
+
+	// cached
+	if len(ts.grants) != 1 || ts.grants[0] != "client_credentials" || ts.scope != "read write" {
+		t.Error(ts.grants, ts.scope)
+		return
+	}
+
+	token, _ := auth.Token(context.Background())
+	if token.AccessToken != "t1" || time.Until(token.Expiry) < time.Hour-time.Minute {
+		t.Error(token)
+		return
+	}
+}
+
+func TestOAuth2Refresh(t *testing.T) {
+	// expires within the skew
+	ts := newTokenServer(10)
+	defer ts.Close()
+
+	client := New()
+	client.SetAuth(&OAuth2{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "app",
+		ClientSecret: "secret",
+		AuthInForm:   true,
+	})
+
+	for i := 0; i < 3; i++ {
+		data, _, err := client.Request(&Option{
+			URL: ts.URL + "/api",
+		})
+		if err != nil || string(data) != "ok" {
+			t.Error(err, string(data))
+			return
+		}
+	}
+
+	grants := fmt.Sprint(ts.grants)
+	if grants != "[client_credentials refresh_token:r1 refresh_token:r2]" {
+		t.Error(grants)
+		return
+	}
+
+	// refresh token grant from the start
+	ts.grants = nil
+
+	_, err := (&OAuth2{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "app",
+		ClientSecret: "secret",
+		RefreshToken: "initial",
+	}).Token(context.Background())
+	if err != nil || fmt.Sprint(ts.grants) != "[refresh_token:initial]" {
+		t.Error(err, ts.grants)
+		return
+	}
+}
+
+func TestOAuth2SingleFlight(t *testing.T) {
+	ts := newTokenServer(3600)
+	ts.delay = 50 * time.Millisecond
+	defer ts.Close()
+
+	client := New()
+	client.SetAuth(&OAuth2{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "app",
+		ClientSecret: "secret",
+	})
+
+	var wg sync.WaitGroup
+	var failed int32
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			data, _, err := client.Request(&Option{
+				URL: ts.URL + "/api",
+			})
+			if err != nil || string(data) != "ok" {
+				atomic.AddInt32(&failed, 1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if failed != 0 || len(ts.grants) != 1 {
+		t.Error(failed, ts.grants)
+		return
+	}
+}
+
+func TestOAuth2Challenge(t *testing.T) {
+	ts := newTokenServer(3600)
+	defer ts.Close()
+
+	client := New()
+	client.SetAuth(&OAuth2{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "app",
+		ClientSecret: "secret",
+	})
+
+	client.Request(&Option{
+		URL: ts.URL + "/api",
+	})
+
+	ts.revoke()
+
+	// 401 then a fresh token
+	data, res, err := client.Request(&Option{
+		URL: ts.URL + "/api",
+	})
+	if err != nil || res.StatusCode != 200 || string(data) != "ok" {
+		t.Error(err, string(data))
+		return
+	}
+
+	if atomic.LoadInt32(&ts.apiCalls) != 3 || len(ts.grants) != 2 {
+		t.Error(ts.apiCalls, ts.grants)
+		return
+	}
+}
+
+func TestOAuth2Challenge401(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	tokens := newTokenServer(3600)
+	defer tokens.Close()
+
+	client := New()
+	client.SetAuth(&OAuth2{
+		TokenURL:     tokens.URL + "/token",
+		ClientID:     "app",
+		ClientSecret: "secret",
+	})
+
+	_, res, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		t.Error(err)
+		return
+	}
+
+	// once more only
+	if len(tokens.grants) != 2 {
+		t.Error(tokens.grants)
+		return
+	}
+}
+
+func TestOAuth2Error(t *testing.T) {
+	ts := newTokenServer(3600)
+	defer ts.Close()
+
+	_, _, err := New().Request(&Option{
+		URL: ts.URL + "/api",
+		Auth: &OAuth2{
+			TokenURL:     ts.URL + "/token",
+			ClientID:     "app",
+			ClientSecret: "wrong",
+		},
+	})
+
+	var tokenErr *TokenError
+
+	if !errors.As(err, &tokenErr) || tokenErr.StatusCode != 401 || tokenErr.Code != "invalid_client" || tokenErr.Description != "bad secret" {
+		t.Error(err)
+		return
+	}
+
+	if atomic.LoadInt32(&ts.apiCalls) != 0 {
+		t.Error(ts.apiCalls)
+		return
+	}
+}
+
+func TestOAuth2Canceled(t *testing.T) {
+	ts := newTokenServer(3600)
+	ts.delay = 100 * time.Millisecond
+	defer ts.Close()
+
+	auth := &OAuth2{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "app",
+		ClientSecret: "secret",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	impatient := make(chan error)
+
+	go func() {
+		_, err := auth.Token(ctx)
+		impatient <- err
+	}()
+
+	// waits for the same token request
+	time.Sleep(5 * time.Millisecond)
+
+	token, err := auth.Token(context.Background())
+	if err != nil || token.AccessToken != "t1" {
+		t.Error(err)
+		return
+	}
+
+	if err := <-impatient; !errors.Is(err, ErrTimeout) {
+		t.Error(err)
+		return
+	}
+
+	if len(ts.grants) != 1 {
+		t.Error(ts.grants)
+		return
+	}
+}
+
+func TestOAuth2RefreshRejected(t *testing.T) {
+	// expires within the skew
+	ts := newTokenServer(10)
+	ts.refreshStatus = http.StatusBadRequest
+	defer ts.Close()
+
+	auth := &OAuth2{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "app",
+		ClientSecret: "secret",
+	}
+
+	for i := 0; i < 2; i++ {
+		token, err := auth.Token(context.Background())
+		if err != nil || token.AccessToken != fmt.Sprintf("t%d", i+1) {
+			t.Error(err, token)
+			return
+		}
+	}
+
+	client := auth.client
+
+	// back to client credentials, then the new refresh token
+	grants := fmt.Sprint(ts.grants)
+	if grants != "[client_credentials refresh_token:r1 client_credentials]" {
+		t.Error(grants)
+		return
+	}
+
+	// the same client
+	auth.Token(context.Background())
+
+	if auth.client != client {
+		t.Error(auth.client)
+		return
+	}
+
+	// the refresh token grant only
+	ts.grants = nil
+
+	_, err := (&OAuth2{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "app",
+		ClientSecret: "secret",
+		RefreshToken: "dead",
+	}).Token(context.Background())
+
+	var tokenErr *TokenError
+
+	if !errors.As(err, &tokenErr) || tokenErr.Code != "invalid_grant" || fmt.Sprint(ts.grants) != "[refresh_token:dead]" {
+		t.Error(err, ts.grants)
+		return
+	}
+}
+
+func TestOAuth2RefreshUnavailable(t *testing.T) {
+	// expires within the skew
+	ts := newTokenServer(10)
+	defer ts.Close()
+
+	auth := &OAuth2{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "app",
+		ClientSecret: "secret",
+	}
+
+	auth.Token(context.Background())
+	auth.Token(context.Background())
+
+	ts.mu.Lock()
+	ts.refreshStatus = http.StatusServiceUnavailable
+	ts.mu.Unlock()
+
+	_, err := auth.Token(context.Background())
+
+	var tokenErr *TokenError
+
+	if !errors.As(err, &tokenErr) || tokenErr.StatusCode != http.StatusServiceUnavailable {
+		t.Error(err)
+		return
+	}
+
+	ts.mu.Lock()
+	ts.refreshStatus = 0
+	ts.mu.Unlock()
+
+	token, err := auth.Token(context.Background())
+	if err != nil || token.AccessToken != "t3" {
+		t.Error(err, token)
+		return
+	}
+
+	// the rotated refresh token is kept
+	grants := fmt.Sprint(ts.grants)
+	if grants != "[client_credentials refresh_token:r1 refresh_token:r2 refresh_token:r2]" {
+		t.Error(grants)
+		return
+	}
+}
+
+func TestOAuth2AuthInFormSameClient(t *testing.T) {
+	ts := newTokenServer(3600)
+	defer ts.Close()
+
+	client := New()
+
+	auth := &OAuth2{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "app",
+		ClientSecret: "secret",
+		AuthInForm:   true,
+		Client:       client,
+	}
+
+	client.SetAuth(auth)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	data, _, err := client.RequestContext(ctx, &Option{
+		URL: ts.URL + "/api",
+	})
+	if err != nil || string(data) != "ok" {
+		t.Error(err, string(data))
+		return
+	}
+}
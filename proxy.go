@@ -1,20 +1,153 @@
 package request
 
 import (
+	"context"
+	"errors"
+	"net"
 	"net/http"
 	"net/url"
+
+	"golang.org/x/net/proxy"
 )
 
+// ErrProxyScheme is returned for a proxy url scheme other than http, https, socks5 and socks5h
+var ErrProxyScheme = errors.New("request: unsupported proxy scheme")
+
+// ProxyOption holds the #SetProxyWithOption settings
+type ProxyOption struct {
+	Username string // overrides the url user info, basic auth for http(s) proxies
+	Password string
+
+	// http(s) proxies only
+	ConnectHeader *Header // sent with the CONNECT of https requests
+
+	// socks5 proxies only
+	// socks5:// and socks5h:// let the proxy resolve the host by default
+	LocalDNS bool // resolve the host before dialing the proxy
+}
+
 // SetProxy sets client proxy
+// http://, https://, socks5:// and socks5h:// with user info for auth
+// the other transport settings are kept
 func (c *Client) SetProxy(proxyURLStr string) (err error) {
+	return c.SetProxyWithOption(proxyURLStr, nil)
+}
+
+// SetProxyWithOption sets client proxy with auth, CONNECT headers and dns settings, opt can be nil
+func (c *Client) SetProxyWithOption(proxyURLStr string, opt *ProxyOption) (err error) {
 	debug(proxyURLStr)
 
+	if opt == nil {
+		opt = &ProxyOption{}
+	}
+
 	proxyURL, err := url.Parse(proxyURLStr)
 	if err != nil {
 		debug("ERR(url.Parse)", err)
 		return
 	}
 
-	c.httpClient.Transport = &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+	if opt.Username != "" || opt.Password != "" {
+		proxyURL.User = url.UserPassword(opt.Username, opt.Password)
+	}
+
+	var (
+		proxyFunc     func(*http.Request) (*url.URL, error)
+		connectHeader http.Header
+		dial          func(ctx context.Context, network, addr string) (net.Conn, error)
+	)
+
+	switch proxyURL.Scheme {
+	case "http", "https":
+		// basic auth comes from the url user info
+		proxyFunc = http.ProxyURL(proxyURL)
+
+		if opt.ConnectHeader != nil {
+			connectHeader = http.Header{}
+
+			for key, value := range *opt.ConnectHeader {
+				connectHeader.Set(key, value)
+			}
+		}
+
+	case "socks5", "socks5h":
+		if !opt.LocalDNS {
+			proxyFunc = http.ProxyURL(proxyURL)
+			break
+		}
+
+		dial, err = socks5LocalDNS(proxyURL, c.dialer)
+		if err != nil {
+			return
+		}
+
+	default:
+		debug("ERR(scheme)", proxyURL.Scheme)
+		return ErrProxyScheme
+	}
+
+	c.resetProxy()
+
+	c.transport.Proxy = proxyFunc
+	c.transport.ProxyConnectHeader = connectHeader
+
+	if dial != nil {
+		c.transport.DialContext = dial
+	}
+
+	// no connection to the previous proxy is reused
+	c.transport.CloseIdleConnections()
+	return
+}
+
+// socks5LocalDNS returns a dial func through the socks5 proxy at proxyURL
+// that resolves the host locally and sends the proxy an ip, forward connects to the proxy
+func socks5LocalDNS(proxyURL *url.URL, forward *net.Dialer) (dial func(ctx context.Context, network, addr string) (net.Conn, error), err error) {
+	var auth *proxy.Auth
+
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+
+		auth = &proxy.Auth{
+			User:     proxyURL.User.Username(),
+			Password: password,
+		}
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, forward)
+	if err != nil {
+		debug("ERR(SOCKS5)", err)
+		return
+	}
+
+	contextDialer := dialer.(proxy.ContextDialer)
+
+	dial = func(ctx context.Context, network, addr string) (conn net.Conn, err error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return
+		}
+
+		if net.ParseIP(host) != nil {
+			return contextDialer.DialContext(ctx, network, addr)
+		}
+
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			debug("ERR(LookupIPAddr)", err)
+			return
+		}
+
+		// first ip the proxy can connect to
+		for _, ip := range ips {
+			conn, err = contextDialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return
+			}
+		}
+
+		return
+	}
+
 	return
 }
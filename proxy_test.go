@@ -0,0 +1,363 @@
+package request
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// socks5 address types
+const (
+	socksIPv4   = 1
+	socksDomain = 3
+	socksIPv6   = 4
+)
+
+// testSocks5 is a local socks5 proxy with username/password auth
+// it records the address type of the last CONNECT
+type testSocks5 struct {
+	listener net.Listener
+	username string
+	password string
+
+	mu       sync.Mutex
+	addrType byte
+	host     string
+}
+
+func newTestSocks5(username, password string) *testSocks5 {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	s := &testSocks5{listener: listener, username: username, password: password}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *testSocks5) URL(scheme string) string {
+	return scheme + "://" + s.username + ":" + s.password + "@" + s.listener.Addr().String()
+}
+
+// last returns the address type and host of the last CONNECT
+func (s *testSocks5) last() (addrType byte, host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addrType, s.host
+}
+
+func (s *testSocks5) Close() {
+	s.listener.Close()
+}
+
+func (s *testSocks5) serve(conn net.Conn) {
+	defer conn.Close()
+
+	// greeting: version, methods
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return
+	}
+
+	methods := make([]byte, buf[1])
+	io.ReadFull(conn, methods)
+
+	// username/password
+	conn.Write([]byte{5, 2})
+
+	// auth: version, username, password
+	io.ReadFull(conn, buf)
+	username := make([]byte, buf[1])
+	io.ReadFull(conn, username)
+	io.ReadFull(conn, buf[:1])
+	password := make([]byte, buf[0])
+	io.ReadFull(conn, password)
+
+	if string(username) != s.username || string(password) != s.password {
+		conn.Write([]byte{1, 1})
+		return
+	}
+
+	conn.Write([]byte{1, 0})
+
+	// request: version, command, reserved, address type
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+
+	var host string
+
+	switch header[3] {
+	case socksIPv4, socksIPv6:
+		ip := make(net.IP, 4)
+		if header[3] == socksIPv6 {
+			ip = make(net.IP, 16)
+		}
+
+		io.ReadFull(conn, ip)
+		host = ip.String()
+
+	case socksDomain:
+		io.ReadFull(conn, buf[:1])
+		domain := make([]byte, buf[0])
+		io.ReadFull(conn, domain)
+		host = string(domain)
+	}
+
+	io.ReadFull(conn, buf)
+	port := binary.BigEndian.Uint16(buf)
+
+	s.mu.Lock()
+	s.addrType = header[3]
+	s.host = host
+	s.mu.Unlock()
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+
+	conn.Write([]byte{5, 0, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+// newConnectProxy returns a local http proxy that tunnels CONNECT requests
+// and rejects requests without the Proxy-Authorization and X-Tunnel headers
+func newConnectProxy() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "CONNECT" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		username, password, ok := parseProxyAuth(r)
+		if !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+
+		if r.Header.Get("X-Tunnel") != "one" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+
+		w.WriteHeader(http.StatusOK)
+
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+
+		go io.Copy(target, conn)
+		io.Copy(conn, target)
+	}))
+}
+
+func parseProxyAuth(r *http.Request) (username, password string, ok bool) {
+	req := &http.Request{Header: http.Header{
+		"Authorization": r.Header["Proxy-Authorization"],
+	}}
+
+	return req.BasicAuth()
+}
+
+func TestSetProxyBasicAuth(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := parseProxyAuth(r)
+		if !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+
+		w.Write([]byte(r.URL.String()))
+	}))
+	defer proxy.Close()
+
+	client := New()
+
+	err := client.SetProxyWithOption(proxy.URL, &ProxyOption{
+		Username: "user",
+		Password: "pass",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	data, res, err := client.Request(&Option{
+		URL: "http://example.com/get",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.StatusCode != 200 || string(data) != "http://example.com/get" {
+		t.Error(res.StatusCode, string(data))
+		return
+	}
+}
+
+func TestSetProxyConnect(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tunnel"))
+	}))
+	defer ts.Close()
+
+	proxy := newConnectProxy()
+	defer proxy.Close()
+
+	client := New()
+
+	err := client.SetProxyWithOption(strings.Replace(proxy.URL, "http://", "http://user:pass@", 1), &ProxyOption{
+		ConnectHeader: &Header{
+			"X-Tunnel": "one",
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// trust the test server
+	client.transport.TLSClientConfig = ts.Client().Transport.(*http.Transport).TLSClientConfig
+
+	data, _, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if string(data) != "tunnel" {
+		t.Error(string(data))
+		return
+	}
+
+	// no custom header
+	client.SetProxy(strings.Replace(proxy.URL, "http://", "http://user:pass@", 1))
+
+	_, _, err = client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err == nil {
+		t.Error()
+		return
+	}
+}
+
+func TestSetProxySocks5(t *testing.T) {
+	ts := newEchoServer()
+	defer ts.Close()
+
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	socks := newTestSocks5("user", "pass")
+	defer socks.Close()
+
+	cases := []struct {
+		scheme   string
+		localDNS bool
+		addrType byte
+	}{
+		{"socks5", false, socksDomain},
+		{"socks5h", false, socksDomain},
+		{"socks5", true, socksIPv4},
+	}
+
+	for _, c := range cases {
+		client := New()
+
+		err := client.SetProxyWithOption(socks.URL(c.scheme), &ProxyOption{
+			LocalDNS: c.localDNS,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		data, _, err := client.Request(&Option{
+			URL: "http://localhost:" + port,
+			Header: &Header{
+				"X-Echo": c.scheme,
+			},
+		})
+		if err != nil {
+			t.Error(c, err)
+			return
+		}
+
+		if string(data) != c.scheme {
+			t.Error(c, string(data))
+			return
+		}
+
+		addrType, host := socks.last()
+		if addrType != c.addrType {
+			t.Error(c, addrType, host)
+			return
+		}
+	}
+}
+
+func TestSetProxySocks5Auth(t *testing.T) {
+	socks := newTestSocks5("user", "pass")
+	defer socks.Close()
+
+	client := New()
+
+	err := client.SetProxyWithOption(socks.URL("socks5"), &ProxyOption{
+		Username: "user",
+		Password: "wrong",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, _, err = client.Request(&Option{
+		URL: "http://localhost/",
+	})
+	if err == nil {
+		t.Error()
+		return
+	}
+}
+
+func TestSetProxyScheme(t *testing.T) {
+	err := New().SetProxy("ftp://127.0.0.1:21")
+	if err != ErrProxyScheme {
+		t.Error(err)
+		return
+	}
+}
@@ -0,0 +1,339 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProxyStrategy is how a #ProxyPool picks the proxy of a request
+type ProxyStrategy int
+
+// proxy strategies
+const (
+	ProxyRoundRobin ProxyStrategy = iota
+	ProxyRandom
+	ProxySticky // same proxy for the same host while it is healthy
+)
+
+const (
+	// DefaultProxyMaxFailures is the default number of failures in a row that evicts a proxy
+	DefaultProxyMaxFailures = 1
+
+	// DefaultProxyCoolDown is the default time an evicted proxy is out of the pool
+	DefaultProxyCoolDown = time.Minute
+)
+
+// ErrNoProxy is returned when every proxy of the pool is evicted
+var ErrNoProxy = errors.New("request: no healthy proxy")
+
+// ProxyPoolOption holds the #ProxyPool settings
+type ProxyPoolOption struct {
+	Strategy    ProxyStrategy // default: ProxyRoundRobin
+	MaxFailures int           // default: DefaultProxyMaxFailures
+	CoolDown    time.Duration // default: DefaultProxyCoolDown
+}
+
+// ProxyStats holds the usage of a proxy of a #ProxyPool
+type ProxyStats struct {
+	URL                 string
+	Requests            int
+	Failures            int
+	ConsecutiveFailures int
+	Evictions           int
+	Evicted             bool
+	EvictedUntil        time.Time
+	LastError           string
+}
+
+// ProxyPool rotates the requests of a #Client across proxies
+// a proxy failing to connect or to open a CONNECT tunnel, or answering 407 or 429 is a failure,
+// an error of the origin behind it is not
+// after MaxFailures in a row it is evicted for CoolDown
+type ProxyPool struct {
+	strategy    ProxyStrategy
+	maxFailures int
+	coolDown    time.Duration
+	transport   *http.Transport
+
+	mu      sync.Mutex
+	proxies []*proxyState
+	next    int
+	sticky  map[string]*proxyState
+	now     func() time.Time
+}
+
+type proxyState struct {
+	url   *url.URL
+	stats ProxyStats
+}
+
+type proxyKey struct{}
+
+// connectKey holds the *int32 status of a refused CONNECT, 0 if none
+type connectKey struct{}
+
+// NewProxyPool returns a new ProxyPool of proxyURLStrs, opt can be nil
+func NewProxyPool(proxyURLStrs []string, opt *ProxyPoolOption) (pool *ProxyPool, err error) {
+	debug(proxyURLStrs)
+
+	if opt == nil {
+		opt = &ProxyPoolOption{}
+	}
+
+	pool = &ProxyPool{
+		strategy:    opt.Strategy,
+		maxFailures: opt.MaxFailures,
+		coolDown:    opt.CoolDown,
+		sticky:      map[string]*proxyState{},
+		now:         time.Now,
+	}
+
+	if pool.maxFailures <= 0 {
+		pool.maxFailures = DefaultProxyMaxFailures
+	}
+
+	if pool.coolDown <= 0 {
+		pool.coolDown = DefaultProxyCoolDown
+	}
+
+	for _, proxyURLStr := range proxyURLStrs {
+		proxyURL, err := url.Parse(proxyURLStr)
+		if err != nil {
+			debug("ERR(url.Parse)", err)
+			return nil, err
+		}
+
+		pool.proxies = append(pool.proxies, &proxyState{
+			url:   proxyURL,
+			stats: ProxyStats{URL: proxyURL.String()},
+		})
+	}
+
+	if len(pool.proxies) == 0 {
+		return nil, ErrNoProxy
+	}
+
+	pool.transport = http.DefaultTransport.(*http.Transport).Clone()
+	pool.transport.Proxy = proxyFromContext
+	pool.transport.OnProxyConnectResponse = proxyConnectResponse
+
+	return
+}
+
+// SetProxyPool sends the client requests through pool
+// with the client transport settings
+func (c *Client) SetProxyPool(pool *ProxyPool) {
+	debug()
+
+	c.resetProxy()
+	c.transport.Proxy = proxyFromContext
+	c.transport.OnProxyConnectResponse = proxyConnectResponse
+	c.transport.CloseIdleConnections()
+
+	c.httpClient.Transport = &poolTransport{pool: pool, transport: c.transport}
+}
+
+// poolTransport sends the requests of a #Client through a #ProxyPool
+type poolTransport struct {
+	pool      *ProxyPool
+	transport *http.Transport
+}
+
+func (t *poolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.pool.roundTrip(req, t.transport)
+}
+
+// RoundTrip implements http.RoundTripper
+func (p *ProxyPool) RoundTrip(req *http.Request) (res *http.Response, err error) {
+	return p.roundTrip(req, p.transport)
+}
+
+// roundTrip sends req with transport through the proxy picked for it
+// transport must get its proxy from proxyFromContext and its CONNECT responses to proxyConnectResponse
+func (p *ProxyPool) roundTrip(req *http.Request, transport *http.Transport) (res *http.Response, err error) {
+	proxy, err := p.pick(req.URL.Host)
+	if err != nil {
+		debug("ERR(pick)", err)
+		return
+	}
+
+	var connectStatus int32
+
+	ctx := context.WithValue(req.Context(), proxyKey{}, proxy.url)
+	ctx = context.WithValue(ctx, connectKey{}, &connectStatus)
+
+	res, err = transport.RoundTrip(req.WithContext(ctx))
+
+	// the caller gave up, not the proxy
+	if req.Context().Err() != nil {
+		return
+	}
+
+	p.report(proxy, res, err, int(atomic.LoadInt32(&connectStatus)))
+	return
+}
+
+// Stats returns the stats of every proxy in the pool order
+func (p *ProxyPool) Stats() (stats []ProxyStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, proxy := range p.proxies {
+		p.readmit(proxy)
+		stats = append(stats, proxy.stats)
+	}
+
+	return
+}
+
+// pick returns the proxy for a request to host
+func (p *ProxyPool) pick(host string) (proxy *proxyState, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var healthy []*proxyState
+
+	for _, proxy := range p.proxies {
+		if p.readmit(proxy) {
+			healthy = append(healthy, proxy)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return nil, ErrNoProxy
+	}
+
+	switch p.strategy {
+	case ProxyRandom:
+		return healthy[rand.Intn(len(healthy))], nil
+
+	case ProxySticky:
+		proxy = p.sticky[host]
+		if proxy != nil && !proxy.stats.Evicted {
+			return
+		}
+
+		proxy = p.roundRobin()
+		p.sticky[host] = proxy
+		return
+	}
+
+	return p.roundRobin(), nil
+}
+
+// roundRobin returns the next healthy proxy, p.mu must be held
+func (p *ProxyPool) roundRobin() *proxyState {
+	for i := 0; i < len(p.proxies); i++ {
+		proxy := p.proxies[(p.next+i)%len(p.proxies)]
+
+		if !proxy.stats.Evicted {
+			p.next = (p.next + i + 1) % len(p.proxies)
+			return proxy
+		}
+	}
+
+	return nil
+}
+
+// readmit re-admits proxy after its cool-down, it returns true if proxy is healthy
+// p.mu must be held
+func (p *ProxyPool) readmit(proxy *proxyState) bool {
+	if proxy.stats.Evicted && !p.now().Before(proxy.stats.EvictedUntil) {
+		debug("READMIT", proxy.stats.URL)
+
+		proxy.stats.Evicted = false
+		proxy.stats.EvictedUntil = time.Time{}
+		proxy.stats.ConsecutiveFailures = 0
+	}
+
+	return !proxy.stats.Evicted
+}
+
+// report records the result of a request sent through proxy,
+// connectStatus is the status of a refused CONNECT, 0 if none
+func (p *ProxyPool) report(proxy *proxyState, res *http.Response, err error, connectStatus int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	proxy.stats.Requests++
+
+	switch {
+	case err != nil && (proxyStatus(connectStatus) || proxyFailure(err)):
+		proxy.stats.LastError = err.Error()
+
+	case err != nil:
+		// the origin failed, not the proxy
+		return
+
+	case proxyStatus(res.StatusCode):
+		proxy.stats.LastError = res.Status
+
+	default:
+		proxy.stats.ConsecutiveFailures = 0
+		return
+	}
+
+	proxy.stats.Failures++
+	proxy.stats.ConsecutiveFailures++
+
+	if proxy.stats.ConsecutiveFailures < p.maxFailures {
+		return
+	}
+
+	debug("EVICT", proxy.stats.URL, proxy.stats.LastError)
+
+	proxy.stats.Evicted = true
+	proxy.stats.EvictedUntil = p.now().Add(p.coolDown)
+	proxy.stats.Evictions++
+
+	// the hosts get another proxy, the map does not keep growing
+	for host, sticky := range p.sticky {
+		if sticky == proxy {
+			delete(p.sticky, host)
+		}
+	}
+}
+
+// proxyStatus returns true if a proxy answering statusCode failed
+func proxyStatus(statusCode int) bool {
+	return statusCode == http.StatusProxyAuthRequired || statusCode == http.StatusTooManyRequests
+}
+
+// proxyFailure returns true if err comes from dialing the proxy or setting up its tunnel
+func proxyFailure(err error) bool {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+
+	switch opErr.Op {
+	case "dial", "proxyconnect", "socks connect":
+		return true
+	}
+
+	return false
+}
+
+// proxyFromContext returns the proxy picked for req by a #ProxyPool
+func proxyFromContext(req *http.Request) (*url.URL, error) {
+	proxyURL, _ := req.Context().Value(proxyKey{}).(*url.URL)
+	return proxyURL, nil
+}
+
+// proxyConnectResponse records the status of a CONNECT refused by the proxy,
+// http.Transport returns it as a plain error
+func proxyConnectResponse(ctx context.Context, proxyURL *url.URL, req *http.Request, res *http.Response) error {
+	connectStatus, _ := ctx.Value(connectKey{}).(*int32)
+	if connectStatus != nil && res.StatusCode != http.StatusOK {
+		atomic.StoreInt32(connectStatus, int32(res.StatusCode))
+	}
+
+	return nil
+}
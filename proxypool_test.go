@@ -0,0 +1,311 @@
+package request
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestProxy returns a local http proxy that answers status with its name
+func newTestProxy(name string, status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// proxied requests carry the absolute url
+		if !r.URL.IsAbs() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(status)
+		w.Write([]byte(name))
+	}))
+}
+
+// proxyNames sends n requests to host through client and returns the proxy names
+func proxyNames(client *Client, host string, n int) (names []string, err error) {
+	for i := 0; i < n; i++ {
+		data, _, err := client.Request(&Option{
+			URL: "http://" + host + "/",
+		})
+		if err != nil {
+			return names, err
+		}
+
+		names = append(names, string(data))
+	}
+
+	return
+}
+
+func TestProxyPoolRoundRobin(t *testing.T) {
+	one := newTestProxy("one", 200)
+	defer one.Close()
+	two := newTestProxy("two", 200)
+	defer two.Close()
+
+	pool, err := NewProxyPool([]string{one.URL, two.URL}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	client := New()
+	client.SetProxyPool(pool)
+
+	names, err := proxyNames(client, "example.com", 4)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if names[0] != "one" || names[1] != "two" || names[2] != "one" || names[3] != "two" {
+		t.Error(names)
+		return
+	}
+
+	stats := pool.Stats()
+	if stats[0].Requests != 2 || stats[1].Requests != 2 {
+		t.Error(stats)
+		return
+	}
+}
+
+func TestProxyPoolRandom(t *testing.T) {
+	one := newTestProxy("one", 200)
+	defer one.Close()
+
+	pool, _ := NewProxyPool([]string{one.URL}, &ProxyPoolOption{Strategy: ProxyRandom})
+
+	client := New()
+	client.SetProxyPool(pool)
+
+	names, err := proxyNames(client, "example.com", 2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if names[0] != "one" || names[1] != "one" {
+		t.Error(names)
+		return
+	}
+}
+
+func TestProxyPoolSticky(t *testing.T) {
+	one := newTestProxy("one", 200)
+	defer one.Close()
+	two := newTestProxy("two", 200)
+	defer two.Close()
+
+	pool, _ := NewProxyPool([]string{one.URL, two.URL}, &ProxyPoolOption{Strategy: ProxySticky})
+
+	client := New()
+	client.SetProxyPool(pool)
+
+	names, _ := proxyNames(client, "example.com", 3)
+	if names[0] != "one" || names[1] != "one" || names[2] != "one" {
+		t.Error(names)
+		return
+	}
+
+	names, _ = proxyNames(client, "example.org", 2)
+	if names[0] != "two" || names[1] != "two" {
+		t.Error(names)
+		return
+	}
+}
+
+func TestProxyPoolStickyEvict(t *testing.T) {
+	limited := newTestProxy("limited", http.StatusTooManyRequests)
+	defer limited.Close()
+	ok := newTestProxy("ok", 200)
+	defer ok.Close()
+
+	pool, _ := NewProxyPool([]string{limited.URL, ok.URL}, &ProxyPoolOption{Strategy: ProxySticky})
+
+	client := New()
+	client.SetProxyPool(pool)
+
+	names, _ := proxyNames(client, "example.com", 1)
+	if names[0] != "limited" {
+		t.Error(names)
+		return
+	}
+
+	// evicted with its hosts
+	if _, found := pool.sticky["example.com"]; found || len(pool.sticky) != 0 {
+		t.Error(pool.sticky)
+		return
+	}
+
+	names, _ = proxyNames(client, "example.com", 2)
+	if names[0] != "ok" || names[1] != "ok" || pool.sticky["example.com"].stats.URL != ok.URL {
+		t.Error(names)
+		return
+	}
+}
+
+func TestProxyPoolEvict(t *testing.T) {
+	limited := newTestProxy("limited", http.StatusTooManyRequests)
+	defer limited.Close()
+	auth := newTestProxy("auth", http.StatusProxyAuthRequired)
+	defer auth.Close()
+	ok := newTestProxy("ok", 200)
+	defer ok.Close()
+
+	// nothing listens there
+	down := httptest.NewServer(nil)
+	down.Close()
+
+	pool, _ := NewProxyPool([]string{limited.URL, down.URL, auth.URL, ok.URL}, &ProxyPoolOption{CoolDown: time.Minute})
+
+	now := time.Now()
+	pool.now = func() time.Time {
+		return now
+	}
+
+	client := New()
+	client.SetProxyPool(pool)
+
+	// one round of failures
+	for i := 0; i < 4; i++ {
+		client.Request(&Option{
+			URL: "http://example.com/",
+		})
+	}
+
+	names, err := proxyNames(client, "example.com", 2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if names[0] != "ok" || names[1] != "ok" {
+		t.Error(names)
+		return
+	}
+
+	stats := pool.Stats()
+	for i := 0; i < 3; i++ {
+		if !stats[i].Evicted || stats[i].Failures != 1 || stats[i].LastError == "" {
+			t.Error(stats[i])
+			return
+		}
+	}
+
+	if stats[3].Evicted || stats[3].Requests != 3 {
+		t.Error(stats[3])
+		return
+	}
+
+	// cool-down is over
+	now = now.Add(time.Minute)
+
+	stats = pool.Stats()
+	if stats[0].Evicted || stats[0].Evictions != 1 {
+		t.Error(stats[0])
+		return
+	}
+}
+
+func TestProxyPoolNoProxy(t *testing.T) {
+	down := httptest.NewServer(nil)
+	down.Close()
+
+	pool, _ := NewProxyPool([]string{down.URL}, nil)
+
+	client := New()
+	client.SetProxyPool(pool)
+
+	_, _, err := client.Request(&Option{
+		URL: "http://example.com/",
+	})
+	if err == nil {
+		t.Error()
+		return
+	}
+
+	_, _, err = client.Request(&Option{
+		URL: "http://example.com/",
+	})
+	if !errors.Is(err, ErrNoProxy) {
+		t.Error(err)
+		return
+	}
+
+	_, err = NewProxyPool(nil, nil)
+	if err != ErrNoProxy {
+		t.Error(err)
+		return
+	}
+}
+
+// newTunnelProxy returns a local http proxy that answers CONNECT requests with status
+// and tunnels them on 200
+func newTunnelProxy(status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "CONNECT" || status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+
+		w.WriteHeader(http.StatusOK)
+
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+
+		go io.Copy(target, conn)
+		io.Copy(conn, target)
+	}))
+}
+
+func TestProxyPoolOriginError(t *testing.T) {
+	// untrusted certificate
+	origin := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer origin.Close()
+
+	tunnel := newTunnelProxy(http.StatusOK)
+	defer tunnel.Close()
+
+	auth := newTunnelProxy(http.StatusProxyAuthRequired)
+	defer auth.Close()
+
+	pool, _ := NewProxyPool([]string{tunnel.URL, auth.URL}, nil)
+
+	client := New()
+	client.SetProxyPool(pool)
+
+	for i := 0; i < 2; i++ {
+		_, _, err := client.Request(&Option{
+			URL: origin.URL,
+		})
+		if err == nil {
+			t.Error(i)
+			return
+		}
+	}
+
+	stats := pool.Stats()
+
+	// the origin failed in the tunnel
+	if stats[0].Evicted || stats[0].Failures != 0 || stats[0].Requests != 1 {
+		t.Error(stats[0])
+		return
+	}
+
+	// CONNECT 407
+	if !stats[1].Evicted || stats[1].Failures != 1 {
+		t.Error(stats[1])
+		return
+	}
+}
@@ -0,0 +1,138 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// DefaultMaxRedirects is the default number of redirects that stops a request, as http.Client
+	DefaultMaxRedirects = 10
+)
+
+var (
+	// ErrRedirectLimit is returned on the RedirectPolicy.MaxRedirects redirect of a request, instead of following it
+	ErrRedirectLimit = errors.New("request: too many redirects")
+
+	// ErrCrossHostRedirect is returned for a redirect to another host with RedirectPolicy.SameHostOnly
+	ErrCrossHostRedirect = errors.New("request: cross-host redirect")
+)
+
+// RedirectPolicy holds the #Client redirect settings
+type RedirectPolicy struct {
+	MaxRedirects int  // default: DefaultMaxRedirects
+	NoFollow     bool // return the 3xx response as is
+	SameHostOnly bool // a redirect to another host returns ErrCrossHostRedirect
+
+	// sent on the redirects to the host of the first request only, like "Authorization"
+	// http.Client already drops Authorization and Cookie for other domains but not subdomains
+	SameHostHeaders []string
+}
+
+// Redirect is a hop of a redirect chain
+type Redirect struct {
+	URL        *url.URL // redirected from
+	StatusCode int
+}
+
+type redirectKey struct{}
+
+// redirectChain is filled by the redirect policy while a request is sent
+type redirectChain struct {
+	redirects []Redirect
+}
+
+// SetRedirect sets client redirect policy, nil restores the http.Client behavior
+func (c *Client) SetRedirect(policy *RedirectPolicy) {
+	debug(policy)
+
+	c.redirect = policy
+}
+
+// Redirects returns the redirects followed to get res, in order
+func Redirects(res *http.Response) []Redirect {
+	if res == nil || res.Request == nil {
+		return nil
+	}
+
+	chain, _ := res.Request.Context().Value(redirectKey{}).(*redirectChain)
+	if chain == nil {
+		return nil
+	}
+
+	return chain.redirects
+}
+
+// withRedirectChain returns ctx with an empty redirect chain
+func withRedirectChain(ctx context.Context) context.Context {
+	return context.WithValue(ctx, redirectKey{}, &redirectChain{})
+}
+
+// resetRedirectChain empties the redirect chain of req before an attempt
+func resetRedirectChain(req *http.Request) {
+	chain, _ := req.Context().Value(redirectKey{}).(*redirectChain)
+	if chain != nil {
+		chain.redirects = nil
+	}
+}
+
+// checkRedirect is the http.Client CheckRedirect of the client
+// via is the requests made so far, oldest first
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	policy := c.redirect
+	if policy == nil {
+		policy = &RedirectPolicy{}
+	}
+
+	if policy.NoFollow {
+		return http.ErrUseLastResponse
+	}
+
+	// each request of via got a redirect response
+	chain, _ := req.Context().Value(redirectKey{}).(*redirectChain)
+	if chain != nil {
+		chain.redirects = nil
+
+		for i, viaReq := range via {
+			res := req.Response
+			if i+1 < len(via) {
+				res = via[i+1].Response
+			}
+
+			chain.redirects = append(chain.redirects, Redirect{
+				URL:        viaReq.URL,
+				StatusCode: res.StatusCode,
+			})
+		}
+	}
+
+	maxRedirects := policy.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+
+	// as http.Client
+	if len(via) >= maxRedirects {
+		debug("ERR(redirect)", len(via))
+		return fmt.Errorf("%w: %d", ErrRedirectLimit, maxRedirects)
+	}
+
+	sameHost := req.URL.Host == via[0].URL.Host
+
+	if policy.SameHostOnly && !sameHost {
+		debug("ERR(redirect)", req.URL.Host)
+		return fmt.Errorf("%w: %s", ErrCrossHostRedirect, req.URL.Host)
+	}
+
+	if !sameHost {
+		for _, key := range policy.SameHostHeaders {
+			req.Header.Del(key)
+		}
+	}
+
+	c.logRedirect(req, via)
+	return nil
+}
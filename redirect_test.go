@@ -0,0 +1,255 @@
+package request
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newRedirectServer redirects /a to /b to /c, /c answers the Authorization and X-Token headers
+// /count redirects the "n" query times
+// /out redirects to the url of the "to" query
+func newRedirectServer() *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+	})
+
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/c", http.StatusFound)
+	})
+
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Token")))
+	})
+
+	mux.HandleFunc("/count", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		if n > 0 {
+			http.Redirect(w, r, "/count?n="+strconv.Itoa(n-1), http.StatusFound)
+			return
+		}
+
+		w.Write([]byte("done"))
+	})
+
+	mux.HandleFunc("/out", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusTemporaryRedirect)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestRedirects(t *testing.T) {
+	ts := newRedirectServer()
+	defer ts.Close()
+
+	client := New()
+
+	res, err := client.Do(&Option{
+		URL: ts.URL + "/a",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	redirects := res.Redirects()
+	if len(redirects) != 2 {
+		t.Error(redirects)
+		return
+	}
+
+	if redirects[0].URL.Path != "/a" || redirects[0].StatusCode != http.StatusMovedPermanently {
+		t.Error(redirects[0])
+		return
+	}
+
+	if redirects[1].URL.Path != "/b" || redirects[1].StatusCode != http.StatusFound {
+		t.Error(redirects[1])
+		return
+	}
+
+	if res.URL().Path != "/c" {
+		t.Error(res.URL())
+		return
+	}
+
+	// no redirect
+	res, _ = client.Do(&Option{
+		URL: ts.URL + "/c",
+	})
+	if res.Redirects() != nil {
+		t.Error(res.Redirects())
+		return
+	}
+}
+
+func TestRedirectLimit(t *testing.T) {
+	ts := newRedirectServer()
+	defer ts.Close()
+
+	client := New()
+	client.SetRedirect(&RedirectPolicy{
+		MaxRedirects: 2,
+	})
+
+	_, _, err := client.Request(&Option{
+		URL: ts.URL + "/a",
+	})
+	if !errors.Is(err, ErrRedirectLimit) {
+		t.Error(err)
+		return
+	}
+
+	_, _, err = client.Request(&Option{
+		URL: ts.URL + "/b",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the default stops on the same redirect as http.Client
+	client.SetRedirect(&RedirectPolicy{})
+
+	for _, n := range []int{DefaultMaxRedirects - 1, DefaultMaxRedirects} {
+		_, _, err = client.Request(&Option{
+			URL: ts.URL + "/count?n=" + strconv.Itoa(n),
+		})
+
+		_, httpErr := http.Get(ts.URL + "/count?n=" + strconv.Itoa(n))
+
+		if errors.Is(err, ErrRedirectLimit) != (httpErr != nil) {
+			t.Error(n, err, httpErr)
+			return
+		}
+	}
+
+	if !errors.Is(err, ErrRedirectLimit) {
+		t.Error(err)
+		return
+	}
+}
+
+func TestRedirectNoFollow(t *testing.T) {
+	ts := newRedirectServer()
+	defer ts.Close()
+
+	client := New()
+	client.SetRedirect(&RedirectPolicy{
+		NoFollow: true,
+	})
+
+	res, err := client.Do(&Option{
+		URL: ts.URL + "/a",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.StatusCode() != http.StatusMovedPermanently || res.HeaderValue("Location") != "/b" || len(res.Redirects()) != 0 {
+		t.Error(res.Status(), res.Header())
+		return
+	}
+
+	// http.Client behavior
+	client.SetRedirect(nil)
+
+	res, err = client.Do(&Option{
+		URL: ts.URL + "/a",
+	})
+	if err != nil || res.URL().Path != "/c" {
+		t.Error(err)
+		return
+	}
+}
+
+func TestRedirectSameHost(t *testing.T) {
+	ts := newRedirectServer()
+	defer ts.Close()
+
+	other := newRedirectServer()
+	defer other.Close()
+
+	header := &Header{
+		"Authorization": "Bearer one",
+		"X-Token":       "two",
+	}
+
+	client := New()
+	client.SetRedirect(&RedirectPolicy{
+		SameHostHeaders: []string{"Authorization", "X-Token"},
+	})
+
+	// same host
+	data, _, err := client.Request(&Option{
+		URL:    ts.URL + "/a",
+		Header: header,
+	})
+	if err != nil || string(data) != "Bearer one|two" {
+		t.Error(err, string(data))
+		return
+	}
+
+	// another port is another host
+	data, _, err = client.Request(&Option{
+		URL: ts.URL + "/out",
+		Query: &Data{
+			"to": []string{other.URL + "/c"},
+		},
+		Header: header,
+	})
+	if err != nil || string(data) != "|" {
+		t.Error(err, string(data))
+		return
+	}
+
+	client.SetRedirect(&RedirectPolicy{
+		SameHostOnly: true,
+	})
+
+	_, _, err = client.Request(&Option{
+		URL: ts.URL + "/out",
+		Query: &Data{
+			"to": []string{other.URL + "/c"},
+		},
+	})
+	if !errors.Is(err, ErrCrossHostRedirect) || !strings.Contains(err.Error(), other.Listener.Addr().String()) {
+		t.Error(err)
+		return
+	}
+}
+
+func TestRedirectRetry(t *testing.T) {
+	ts, _ := newFlakyServer(1, http.StatusBadGateway, nil)
+	defer ts.Close()
+
+	redirect := newRedirectServer()
+	defer redirect.Close()
+
+	client := New()
+	client.SetRetry(newTestRetryPolicy())
+
+	res, err := client.Do(&Option{
+		URL: redirect.URL + "/out",
+		Query: &Data{
+			"to": []string{ts.URL},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// one redirect per attempt
+	if res.Attempts() != 2 || len(res.Redirects()) != 1 {
+		t.Error(res.Attempts(), res.Redirects())
+		return
+	}
+}
@@ -1,10 +1,15 @@
 package request
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"mime/multipart"
+	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strconv"
 	"strings"
@@ -20,32 +25,67 @@ const (
 
 var debug = dlog.New("request", nil)
 
+var (
+	// ErrCanceled is returned when the request context is canceled
+	ErrCanceled = errors.New("request: canceled")
+
+	// ErrTimeout is returned when the context deadline or the client timeout is exceeded
+	ErrTimeout = errors.New("request: timeout")
+)
+
 // Client is an http client that hold init settings and cookies
 type Client struct {
 	httpClient *http.Client
+	retry      *RetryPolicy
+	hooks      hooks
+	defaults   *Defaults
+
+	acceptStatus []StatusRange
+	redirect     *RedirectPolicy
+	logger       *clientLogger
+	instrument   Instrument
+	auth         Authenticator
+	encoding     *EncodingOption
+	compress     *CompressOption
+
+	// owned by the client, setters change it in place
+	transport *http.Transport
+	dialer    *net.Dialer
 }
 
 // New return a new Client
+// cookies are kept in memory by a #Jar
 func New() *Client {
-	var cookie, _ = cookiejar.New(nil)
+	// a memory jar never fails
+	jar, _ := NewJar(nil)
 
-	client := &http.Client{
-		Timeout: time.Second * DefaultTimeout,
-		Jar:     cookie,
-	}
-
-	debug()
-	return &Client{client}
+	return NewWithJar(jar)
 }
 
 // NewNoCookie return a new Client that won't save cookies
 func NewNoCookie() *Client {
+	debug()
+	return newClient(nil)
+}
+
+// newClient returns a new Client with its own transport, jar can be nil
+func newClient(jar http.CookieJar) *Client {
+	transport, dialer := newTransport()
+
 	client := &http.Client{
-		Timeout: time.Second * DefaultTimeout,
+		Timeout:   time.Second * DefaultTimeout,
+		Jar:       jar,
+		Transport: transport,
 	}
 
-	debug()
-	return &Client{client}
+	c := &Client{
+		httpClient: client,
+		transport:  transport,
+		dialer:     dialer,
+	}
+
+	client.CheckRedirect = c.checkRedirect
+	return c
 }
 
 // Data is the body of http request
@@ -61,10 +101,21 @@ type Option struct {
 	BodyStr  string
 	Body     *Data
 	Form     *Data       // set Content-Type header as "application/x-www-form-urlencoded"
+	Files    []*File     // set Content-Type header as "multipart/form-data", Form is sent as fields
 	JSON     interface{} // set Content-Type header as "application/json"
 	Query    *Data
 	QueryRaw string
 	Header   *Header
+	Context  context.Context // default: context.Background()
+
+	Result      interface{} // decodes a 2xx json response
+	ErrorResult interface{} // decodes a non-2xx json response
+
+	AcceptStatus []StatusRange // return a *StatusError out of these ranges, overrides #SetStatusError
+
+	Auth Authenticator // overrides #SetAuth
+
+	Compress *CompressOption // overrides #SetCompress
 }
 
 // SetTimeout sets client timeout
@@ -75,58 +126,243 @@ func (c *Client) SetTimeout(timeout time.Duration) {
 }
 
 // Request sends http request
+// opt.Context is used if set
 func (c *Client) Request(opt *Option) (data []byte, res *http.Response, err error) {
-	//set GET as default method
-	if opt.Method == "" {
-		opt.Method = "GET"
+	return c.RequestContext(opt.Context, opt)
+}
+
+// RequestContext sends http request with ctx
+// ctx overrides opt.Context, the request is aborted as soon as ctx is done
+// the earlier of ctx deadline and client timeout wins
+func (c *Client) RequestContext(ctx context.Context, opt *Option) (data []byte, res *http.Response, err error) {
+	ctx = optContext(ctx, opt)
+
+	res, err = c.do(ctx, opt)
+	if err != nil {
+		return
 	}
+	defer res.Body.Close()
 
-	opt.Method = strings.ToUpper(opt.Method)
+	// read all
+	// it's a good practice to read all data so golang http can reuse requests
+	data, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		debug("ERR(ReadAll)", err)
+		err = ctxError(ctx, err)
+		return
+	}
 
-	//url
-	reqURL, err := makeURL(opt.URL, opt.Query)
+	res, data, err = c.runAfter(res, data)
 	if err != nil {
 		return
 	}
 
-	//body
-	reqBody, err := makeBody(opt)
+	err = c.checkStatus(res, data, opt)
+	if err != nil {
+		// ErrorResult is filled when it can be
+		decodeResult(res, data, opt)
+		return
+	}
+
+	err = decodeResult(res, data, opt)
+	return
+}
+
+// do sends http request and returns the response with its body still open
+func (c *Client) do(ctx context.Context, opt *Option) (res *http.Response, err error) {
+	// fail fast
+	if err = ctx.Err(); err != nil {
+		err = ctxError(ctx, err)
+		return
+	}
+
+	opt, err = c.defaults.merge(opt)
 	if err != nil {
 		return
 	}
 
-	req, err := http.NewRequest(opt.Method, reqURL.String()+opt.QueryRaw, strings.NewReader(reqBody))
+	ctx = withRedirectChain(ctx)
+	ctx = withBodyEncoding(ctx)
+	ctx = c.withAuth(ctx, opt)
+
+	req, err := newRequest(ctx, opt)
 	if err != nil {
-		debug("ERR(req)", err)
 		return
 	}
 
-	//header
-	makeHeader(req, opt)
+	c.setAcceptEncoding(req)
+
+	err = c.compressBody(req, opt)
+	if err != nil {
+		return
+	}
+
+	if c.retry == nil {
+		return c.send(ctx, req)
+	}
+
+	return c.sendRetry(ctx, req)
+}
+
+// send sends req once, and once more to answer an auth challenge
+func (c *Client) send(ctx context.Context, req *http.Request) (res *http.Response, err error) {
+	err = c.runBefore(req)
+	if err != nil {
+		return
+	}
+
+	res, err = c.transmit(ctx, req)
+	if err != nil {
+		return
+	}
+
+	retryReq, err := challenge(req, res)
+	if err != nil || retryReq == nil {
+		if err != nil {
+			debug("ERR(challenge)", err)
+			discard(res)
+			res = nil
+		}
+
+		return
+	}
+
+	discard(res)
+
+	return c.transmit(ctx, retryReq)
+}
+
+// transmit authenticates and sends req
+func (c *Client) transmit(ctx context.Context, req *http.Request) (res *http.Response, err error) {
+	if auth := authenticator(req); auth != nil {
+		err = auth.Authenticate(req)
+		if err != nil {
+			debug("ERR(auth)", err)
+			return
+		}
+	}
+
+	resetRedirectChain(req)
+
+	req, finish := c.startInstrument(req)
+	c.logRequest(req)
 
 	debug(req.Method, "\t>", req.URL.String())
 	now := time.Now()
 
-	res, err = c.httpClient.Do(req)
+	res, err = c.roundTrip(req)
+	if err == nil {
+		if err = c.decodeBody(res); err != nil {
+			res = nil
+		}
+	}
+
 	if err != nil {
 		debug("ERR", "\t<", err, humanizeNano(time.Now().Sub(now)))
+		err = ctxError(ctx, err)
+		c.logError(req, err, now)
+		finish(nil, err)
 		return
 	}
-	defer res.Body.Close()
+
+	finish(res, nil)
+	c.logResponse(req, res, now)
 
 	debug(res.StatusCode, "\t<", res.Request.URL, humanizeNano(time.Now().Sub(now)))
+	return
+}
 
-	// read all
-	// it's a good practice to read all data so golang http can reuse requests
-	data, err = ioutil.ReadAll(res.Body)
+// newRequest builds the *http.Request from opt
+func newRequest(ctx context.Context, opt *Option) (req *http.Request, err error) {
+	//set GET as default method
+	if opt.Method == "" {
+		opt.Method = "GET"
+	}
+
+	opt.Method = strings.ToUpper(opt.Method)
+
+	//url
+	reqURL, err := makeURL(opt.URL, opt.Query)
 	if err != nil {
-		debug("ERR(ReadAll)", err)
 		return
 	}
 
+	//body
+	var body io.Reader
+	var boundary string
+
+	if opt.Files != nil {
+		boundary = multipart.NewWriter(nil).Boundary()
+
+		body, err = makeMultipart(opt, boundary)
+		if err != nil {
+			return
+		}
+	} else {
+		reqBody, err := makeBody(opt)
+		if err != nil {
+			return nil, err
+		}
+
+		body = strings.NewReader(reqBody)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, opt.Method, reqURL.String()+opt.QueryRaw, body)
+	if err != nil {
+		debug("ERR(req)", err)
+		return
+	}
+
+	if opt.Files != nil {
+		// rebuild the stream on retry
+		if replayableFiles(opt.Files) {
+			req.GetBody = func() (io.ReadCloser, error) {
+				return makeMultipart(opt, boundary)
+			}
+		}
+
+		// the boundary is only known here, opt.Header still overrides it in makeHeader
+		req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	}
+
+	//header
+	makeHeader(req, opt)
 	return
 }
 
+// optContext returns ctx, falls back to opt.Context then context.Background()
+func optContext(ctx context.Context, opt *Option) context.Context {
+	if ctx != nil {
+		return ctx
+	}
+
+	if opt.Context != nil {
+		return opt.Context
+	}
+
+	return context.Background()
+}
+
+// ctxError maps cancellation and timeout errors to ErrCanceled and ErrTimeout
+// other errors are returned as is
+func ctxError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	// client timeout
+	var netErr interface{ Timeout() bool }
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	return err
+}
+
 func makeURL(urlStr string, query *Data) (u *url.URL, err error) {
 	u, err = url.Parse(urlStr)
 	if err != nil {
@@ -196,6 +432,9 @@ func makeHeader(req *http.Request, opt *Option) {
 	req.Header.Set("User-Agent", " ") // == "" on the host side
 
 	switch {
+	case opt.Files != nil:
+		// "multipart/form-data" with its boundary is set by newRequest
+
 	case opt.Form != nil:
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
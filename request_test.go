@@ -15,12 +15,16 @@ package request
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -639,6 +643,82 @@ func TestRequestFail(t *testing.T) {
 	}
 }
 
+func TestRequestContextCanceled(t *testing.T) {
+	ts := newSlowServer(time.Second)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	client := New()
+
+	data, res, err := client.RequestContext(ctx, &Option{
+		URL: ts.URL,
+	})
+	if !errors.Is(err, ErrCanceled) {
+		t.Error(err)
+		return
+	}
+	if res != nil {
+		t.Error()
+		return
+	}
+	if data != nil {
+		t.Error()
+		return
+	}
+}
+
+func TestRequestContextDeadline(t *testing.T) {
+	ts := newSlowServer(time.Second)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := New()
+
+	_, _, err := client.Request(&Option{
+		URL:     ts.URL,
+		Context: ctx,
+	})
+	if !errors.Is(err, ErrTimeout) {
+		t.Error(err)
+		return
+	}
+}
+
+func TestRequestContextClientTimeout(t *testing.T) {
+	ts := newSlowServer(time.Second)
+	defer ts.Close()
+
+	client := New()
+	client.SetTimeout(50 * time.Millisecond)
+
+	_, _, err := client.RequestContext(context.Background(), &Option{
+		URL: ts.URL,
+	})
+	if !errors.Is(err, ErrTimeout) {
+		t.Error(err)
+		return
+	}
+}
+
+func TestRequestContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := New()
+
+	_, _, err := client.RequestContext(ctx, &Option{
+		URL: "http://127.0.0.1:1",
+	})
+	if !errors.Is(err, ErrCanceled) {
+		t.Error(err)
+		return
+	}
+}
+
 func TestRequestStream(t *testing.T) {
 	client := New()
 
@@ -703,6 +783,18 @@ type httpbinRes struct {
 	Cookies map[string]string      `json:"cookies"`
 }
 
+// newSlowServer returns a local server that responds after delay
+func newSlowServer(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+		}
+
+		w.Write([]byte("slow"))
+	}))
+}
+
 func decodeHttpbinRes(data []byte) *httpbinRes {
 	// debug(string(data))
 
@@ -0,0 +1,129 @@
+package request
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Response is the response of #Do, its body is already read
+type Response struct {
+	Raw *http.Response // its Body is closed
+
+	data     []byte
+	duration time.Duration
+}
+
+// Do sends http request and returns its #Response
+// opt.Context is used if set
+func (c *Client) Do(opt *Option) (res *Response, err error) {
+	return c.DoContext(opt.Context, opt)
+}
+
+// DoContext sends http request with ctx and returns its #Response
+// ctx overrides opt.Context like #RequestContext
+// res is not nil when a response was received, even with err
+func (c *Client) DoContext(ctx context.Context, opt *Option) (res *Response, err error) {
+	now := time.Now()
+
+	data, httpRes, err := c.RequestContext(ctx, opt)
+	if httpRes == nil {
+		return
+	}
+
+	res = &Response{
+		Raw:      httpRes,
+		data:     data,
+		duration: time.Now().Sub(now),
+	}
+
+	return
+}
+
+// StatusCode returns the response status code
+func (r *Response) StatusCode() int {
+	return r.Raw.StatusCode
+}
+
+// Status returns the response status, like "200 OK"
+func (r *Response) Status() string {
+	return r.Raw.Status
+}
+
+// IsSuccess returns true for a 2xx status code
+func (r *Response) IsSuccess() bool {
+	return r.Raw.StatusCode >= 200 && r.Raw.StatusCode < 300
+}
+
+// StatusOK returns true for 200 OK only
+func (r *Response) StatusOK() bool {
+	return r.Raw.StatusCode == http.StatusOK
+}
+
+// Header returns the response header
+func (r *Response) Header() http.Header {
+	return r.Raw.Header
+}
+
+// HeaderValue returns the first value of the response header key
+func (r *Response) HeaderValue(key string) string {
+	return r.Raw.Header.Get(key)
+}
+
+// ContentType returns the response Content-Type header
+func (r *Response) ContentType() string {
+	return r.Raw.Header.Get("Content-Type")
+}
+
+// Cookies returns the cookies set by the response
+func (r *Response) Cookies() []*http.Cookie {
+	return r.Raw.Cookies()
+}
+
+// Bytes returns the response body
+func (r *Response) Bytes() []byte {
+	return r.data
+}
+
+// String returns the response body as string
+func (r *Response) String() string {
+	return string(r.data)
+}
+
+// JSON decodes the response body into v
+func (r *Response) JSON(v interface{}) error {
+	return json.Unmarshal(r.data, v)
+}
+
+// Duration returns the time from sending the request to reading the whole body
+// with every retry
+func (r *Response) Duration() time.Duration {
+	return r.duration
+}
+
+// URL returns the final url of the request, after redirects
+func (r *Response) URL() *url.URL {
+	return r.Raw.Request.URL
+}
+
+// Redirects returns the redirects followed to get the response, in order
+func (r *Response) Redirects() []Redirect {
+	return Redirects(r.Raw)
+}
+
+// Attempts returns the number of attempts made to get the response
+func (r *Response) Attempts() int {
+	return Attempts(r.Raw)
+}
+
+// ContentEncoding returns the Content-Encoding the response was sent with, even when it is decoded
+func (r *Response) ContentEncoding() string {
+	return ContentEncoding(r.Raw)
+}
+
+// CompressedSize returns the number of body bytes read from the wire, -1 if unknown
+func (r *Response) CompressedSize() int64 {
+	return CompressedSize(r.Raw)
+}
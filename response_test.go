@@ -0,0 +1,174 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newResponseServer() *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "one"})
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Total", "2")
+		w.Write([]byte(`{"name":"ddo","ids":[1,2]}`))
+	})
+
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/json", http.StatusFound)
+	})
+
+	mux.HandleFunc("/created", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestDo(t *testing.T) {
+	ts := newResponseServer()
+	defer ts.Close()
+
+	client := New()
+
+	res, err := client.Do(&Option{
+		URL: ts.URL + "/redirect",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !res.StatusOK() || !res.IsSuccess() || res.StatusCode() != 200 || res.Status() != "200 OK" {
+		t.Error(res.Status())
+		return
+	}
+
+	if res.String() != `{"name":"ddo","ids":[1,2]}` || string(res.Bytes()) != res.String() {
+		t.Error(res.String())
+		return
+	}
+
+	var body struct {
+		Name string
+		IDs  []int
+	}
+
+	err = res.JSON(&body)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if body.Name != "ddo" || len(body.IDs) != 2 {
+		t.Error(body)
+		return
+	}
+
+	if res.HeaderValue("X-Total") != "2" || res.Header().Get("X-Total") != "2" || res.ContentType() != "application/json" {
+		t.Error(res.Header())
+		return
+	}
+
+	cookies := res.Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].Value != "one" {
+		t.Error(cookies)
+		return
+	}
+
+	if res.URL().String() != ts.URL+"/json" {
+		t.Error(res.URL())
+		return
+	}
+
+	if res.Duration() <= 0 || res.Attempts() != 1 {
+		t.Error(res.Duration(), res.Attempts())
+		return
+	}
+}
+
+func TestDoStatus(t *testing.T) {
+	ts := newResponseServer()
+	defer ts.Close()
+
+	client := New()
+
+	res, err := client.Do(&Option{
+		URL: ts.URL + "/created",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.StatusOK() || !res.IsSuccess() {
+		t.Error(res.Status())
+		return
+	}
+
+	res, err = client.Do(&Option{
+		URL: ts.URL + "/missing",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.IsSuccess() || res.StatusCode() != http.StatusNotFound {
+		t.Error(res.Status())
+		return
+	}
+}
+
+func TestDoAttempts(t *testing.T) {
+	ts, _ := newFlakyServer(1, http.StatusServiceUnavailable, nil)
+	defer ts.Close()
+
+	client := New()
+	client.SetRetry(newTestRetryPolicy())
+
+	res, err := client.Do(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.Attempts() != 2 || !res.IsSuccess() {
+		t.Error(res.Attempts(), res.Status())
+		return
+	}
+}
+
+func TestDoContext(t *testing.T) {
+	ts := newSlowServer(time.Second)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	client := New()
+
+	res, err := client.DoContext(ctx, &Option{
+		URL: ts.URL,
+	})
+	if !errors.Is(err, ErrTimeout) {
+		t.Error(err)
+		return
+	}
+
+	if res != nil {
+		t.Error(res)
+		return
+	}
+}
@@ -0,0 +1,324 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is the default number of attempts including the first one
+	DefaultMaxAttempts = 3
+
+	// DefaultMinBackoff is the default wait before the second attempt
+	DefaultMinBackoff = 100 * time.Millisecond
+
+	// DefaultMaxBackoff is the default max wait between attempts
+	DefaultMaxBackoff = 10 * time.Second
+)
+
+// RetryPolicy holds the #Client retry settings
+// the zero value makes a single attempt, use NewRetryPolicy for the defaults
+type RetryPolicy struct {
+	MaxAttempts int           // including the first attempt
+	MinBackoff  time.Duration // wait before the second attempt
+	MaxBackoff  time.Duration // max wait between attempts, also caps Retry-After
+	Multiplier  float64       // backoff growth per attempt, < 1 means 1
+	Jitter      float64       // 0 to 1, random part of each wait
+
+	StatusCodes      []int                // retryable response status codes
+	RetryOn          func(err error) bool // retryable errors, nil means none
+	Methods          []string             // retryable methods
+	IgnoreRetryAfter bool                 // do not honor the Retry-After header
+}
+
+// NewRetryPolicy returns a RetryPolicy with default settings
+// it retries idempotent methods on 429, 502, 503, 504 and on RetryableError
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: DefaultMaxAttempts,
+		MinBackoff:  DefaultMinBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		Multiplier:  2,
+		Jitter:      0.5,
+
+		StatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryOn: RetryableError,
+		Methods: []string{"GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE"},
+	}
+}
+
+// SetRetry sets client retry policy, nil disables retries
+func (c *Client) SetRetry(policy *RetryPolicy) {
+	debug(policy)
+
+	c.retry = policy
+}
+
+// RetryableError reports whether err is a transient network error
+// timeouts, connection resets, refused connections and unexpected EOFs
+func RetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// caller gave up
+	if errors.Is(err, ErrCanceled) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	switch {
+	case errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.EPIPE):
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return false
+}
+
+// RetryError is returned when the last of several attempts fails
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("request: %d attempts: %v", e.Attempts, e.Err)
+}
+
+// Unwrap returns the error of the last attempt
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+type attemptKey struct{}
+
+// Attempts returns the number of attempts made to get res
+func Attempts(res *http.Response) int {
+	if res == nil || res.Request == nil {
+		return 0
+	}
+
+	attempt, ok := res.Request.Context().Value(attemptKey{}).(int)
+	if !ok {
+		return 1
+	}
+
+	return attempt
+}
+
+// sendRetry sends req until it succeeds or the policy gives up
+func (c *Client) sendRetry(ctx context.Context, req *http.Request) (res *http.Response, err error) {
+	policy := c.retry
+	attempt := 1
+
+	for ; ; attempt++ {
+		attemptReq, rewindErr := rewind(req, context.WithValue(ctx, attemptKey{}, attempt), attempt)
+		if rewindErr != nil {
+			debug("ERR(rewind)", rewindErr)
+			err = rewindErr
+			break
+		}
+
+		res, err = c.send(ctx, attemptReq)
+
+		if attempt >= policy.MaxAttempts || !replayable(req) || !policy.retryable(req, res, err) {
+			break
+		}
+
+		wait, ok := policy.wait(attempt, res)
+		if !ok {
+			break
+		}
+
+		debug("RETRY", attempt, humanizeNano(wait))
+
+		var statusCode int
+
+		// discard the response we are not going to return
+		if res != nil {
+			statusCode = res.StatusCode
+
+			discard(res)
+			res = nil
+		}
+
+		c.logRetry(attemptReq, statusCode, err, wait)
+
+		if err = sleep(ctx, wait); err != nil {
+			break
+		}
+	}
+
+	if err != nil && attempt > 1 {
+		err = &RetryError{Attempts: attempt, Err: err}
+	}
+
+	return
+}
+
+// discard drains and closes the body of a response we are not going to return
+func discard(res *http.Response) {
+	io.CopyN(ioutil.Discard, res.Body, MaxDrainSize)
+	res.Body.Close()
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctxError(ctx, ctx.Err())
+
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rewind returns a copy of req for the given attempt with a fresh body
+func rewind(req *http.Request, ctx context.Context, attempt int) (*http.Request, error) {
+	if attempt == 1 {
+		return req.WithContext(ctx), nil
+	}
+
+	return replay(req, ctx)
+}
+
+// replay returns a copy of req with ctx and a fresh body
+func replay(req *http.Request, ctx context.Context) (*http.Request, error) {
+	replayReq := req.Clone(ctx)
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+
+		replayReq.Body = body
+	}
+
+	return replayReq, nil
+}
+
+// replayable reports whether req body can be sent again
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func (p *RetryPolicy) retryable(req *http.Request, res *http.Response, err error) bool {
+	if !p.method(req.Method) {
+		return false
+	}
+
+	if err != nil {
+		return p.RetryOn != nil && p.RetryOn(err)
+	}
+
+	for _, code := range p.StatusCodes {
+		if res.StatusCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *RetryPolicy) method(method string) bool {
+	for _, m := range p.Methods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wait returns the backoff before the next attempt
+// ok is false if the server asks to wait longer than MaxBackoff
+func (p *RetryPolicy) wait(attempt int, res *http.Response) (wait time.Duration, ok bool) {
+	multiplier := math.Max(p.Multiplier, 1)
+
+	backoff := float64(p.MinBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 {
+		backoff = math.Min(backoff, float64(p.MaxBackoff))
+	}
+
+	// jitter
+	jitter := math.Min(math.Max(p.Jitter, 0), 1)
+	backoff -= backoff * jitter * rand.Float64()
+
+	wait = time.Duration(backoff)
+
+	if res == nil || p.IgnoreRetryAfter {
+		return wait, true
+	}
+
+	after, found := retryAfter(res.Header.Get("Retry-After"), time.Now())
+	if !found {
+		return wait, true
+	}
+
+	if p.MaxBackoff > 0 && after > p.MaxBackoff {
+		debug("Retry-After too long", after)
+		return 0, false
+	}
+
+	if after > wait {
+		wait = after
+	}
+
+	return wait, true
+}
+
+// retryAfter parses the Retry-After header value
+// either delay-seconds or an http-date
+func retryAfter(value string, now time.Time) (after time.Duration, ok bool) {
+	if value == "" {
+		return
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return
+	}
+
+	after = date.Sub(now)
+	if after < 0 {
+		after = 0
+	}
+
+	return after, true
+}
@@ -0,0 +1,310 @@
+package request
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestRetryPolicy() *RetryPolicy {
+	policy := NewRetryPolicy()
+	policy.MinBackoff = time.Millisecond
+	policy.MaxBackoff = 10 * time.Millisecond
+	return policy
+}
+
+// newFlakyServer returns a local server that fails with status the first n requests
+func newFlakyServer(n int32, status int, header http.Header) (*httptest.Server, *int32) {
+	var counter int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		if atomic.AddInt32(&counter, 1) <= n {
+			for key := range header {
+				w.Header().Set(key, header.Get(key))
+			}
+
+			w.WriteHeader(status)
+			return
+		}
+
+		w.Write(body)
+	}))
+
+	return ts, &counter
+}
+
+func TestRetry(t *testing.T) {
+	ts, counter := newFlakyServer(2, http.StatusBadGateway, nil)
+	defer ts.Close()
+
+	client := New()
+	client.SetRetry(newTestRetryPolicy())
+
+	_, res, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.StatusCode != 200 {
+		t.Error(res.StatusCode)
+		return
+	}
+
+	if *counter != 3 {
+		t.Error(*counter)
+		return
+	}
+
+	if Attempts(res) != 3 {
+		t.Error(Attempts(res))
+		return
+	}
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	ts, counter := newFlakyServer(5, http.StatusServiceUnavailable, nil)
+	defer ts.Close()
+
+	client := New()
+	client.SetRetry(newTestRetryPolicy())
+
+	_, res, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the last response is returned as is
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Error(res.StatusCode)
+		return
+	}
+
+	if *counter != DefaultMaxAttempts {
+		t.Error(*counter)
+		return
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	ts, counter := newFlakyServer(1, http.StatusBadGateway, nil)
+	defer ts.Close()
+
+	client := New()
+	client.SetRetry(newTestRetryPolicy())
+
+	_, res, err := client.Request(&Option{
+		URL:    ts.URL,
+		Method: "POST",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.StatusCode != http.StatusBadGateway {
+		t.Error(res.StatusCode)
+		return
+	}
+
+	if *counter != 1 {
+		t.Error(*counter)
+		return
+	}
+}
+
+func TestRetryBody(t *testing.T) {
+	ts, _ := newFlakyServer(2, http.StatusBadGateway, nil)
+	defer ts.Close()
+
+	policy := newTestRetryPolicy()
+	policy.Methods = append(policy.Methods, "POST")
+
+	client := New()
+	client.SetRetry(policy)
+
+	data, _, err := client.Request(&Option{
+		URL:    ts.URL,
+		Method: "POST",
+		JSON:   map[string]int{"one": 1},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the body is replayed on the last attempt
+	if string(data) != `{"one":1}` {
+		t.Error(string(data))
+		return
+	}
+}
+
+func TestRetryAfterTooLong(t *testing.T) {
+	ts, counter := newFlakyServer(1, http.StatusTooManyRequests, http.Header{
+		"Retry-After": []string{"120"},
+	})
+	defer ts.Close()
+
+	client := New()
+	client.SetRetry(newTestRetryPolicy())
+
+	_, res, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Error(res.StatusCode)
+		return
+	}
+
+	if *counter != 1 {
+		t.Error(*counter)
+		return
+	}
+}
+
+func TestRetryError(t *testing.T) {
+	var counter int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&counter, 1)
+
+		// drop the connection
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.SetRetry(newTestRetryPolicy())
+
+	_, res, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) {
+		t.Error(err)
+		return
+	}
+
+	if retryErr.Attempts != DefaultMaxAttempts {
+		t.Error(retryErr.Attempts)
+		return
+	}
+
+	if res != nil {
+		t.Error()
+		return
+	}
+
+	if n := atomic.LoadInt32(&counter); n != DefaultMaxAttempts {
+		t.Error(n)
+		return
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	after, ok := retryAfter("3", now)
+	if !ok || after != 3*time.Second {
+		t.Error(after)
+		return
+	}
+
+	after, ok = retryAfter("Wed, 01 Jan 2020 00:00:10 GMT", now)
+	if !ok || after != 10*time.Second {
+		t.Error(after)
+		return
+	}
+
+	_, ok = retryAfter("soon", now)
+	if ok {
+		t.Error()
+		return
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	policy := NewRetryPolicy()
+	policy.Jitter = 0
+
+	wait, _ := policy.wait(1, nil)
+	if wait != DefaultMinBackoff {
+		t.Error(wait)
+		return
+	}
+
+	wait, _ = policy.wait(3, nil)
+	if wait != 4*DefaultMinBackoff {
+		t.Error(wait)
+		return
+	}
+
+	wait, _ = policy.wait(20, nil)
+	if wait != DefaultMaxBackoff {
+		t.Error(wait)
+		return
+	}
+}
+
+func TestRetryRewindError(t *testing.T) {
+	ts, _ := newFlakyServer(1, http.StatusServiceUnavailable, nil)
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "upload.txt")
+	ioutil.WriteFile(path, []byte("data"), 0600)
+
+	policy := newTestRetryPolicy()
+	policy.Methods = append(policy.Methods, "POST")
+
+	client := New()
+	client.SetRetry(policy)
+
+	// the file is gone before the second attempt
+	client.Use(func(next DoFunc) DoFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			res, err := next(req)
+			os.Remove(path)
+			return res, err
+		}
+	})
+
+	_, res, err := client.Request(&Option{
+		URL:    ts.URL,
+		Method: "POST",
+		Files: []*File{
+			{Field: "file", Path: path},
+		},
+	})
+
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || !errors.Is(err, os.ErrNotExist) || retryErr.Attempts != 2 {
+		t.Error(err)
+		return
+	}
+
+	if res != nil {
+		t.Error(res)
+		return
+	}
+}
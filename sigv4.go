@@ -0,0 +1,316 @@
+package request
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SigV4 values
+const (
+	SigV4Algorithm  = "AWS4-HMAC-SHA256"
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+	sigV4TimeFormat = "20060102T150405Z"
+	sigV4DateFormat = "20060102"
+)
+
+// ErrNoSigV4 is returned by #Presign without a *SigV4 auth
+var ErrNoSigV4 = errors.New("request: presign needs a *SigV4 auth")
+
+// headers never signed, they may change on the way
+var sigV4SkipHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"expect":          true,
+	"content-length":  true,
+	"x-amzn-trace-id": true,
+}
+
+// SigV4 is the AWS Signature Version 4 #Authenticator
+// it signs every attempt as sent: the final url, the query (rewritten in canonical order),
+// the host, the headers and the payload hash
+type SigV4 struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // temporary credentials
+	Region       string // like "us-east-1"
+	Service      string // like "s3"
+
+	// UnsignedPayload signs "UNSIGNED-PAYLOAD" instead of the body hash
+	// the body is not read in memory
+	UnsignedPayload bool
+}
+
+// Authenticate implements #Authenticator
+func (s *SigV4) Authenticate(req *http.Request) error {
+	return s.sign(req, time.Now())
+}
+
+// sign sets the X-Amz-* and Authorization headers of req at now
+func (s *SigV4) sign(req *http.Request, now time.Time) (err error) {
+	now = now.UTC()
+
+	payload := UnsignedPayload
+
+	if !s.UnsignedPayload {
+		body, err := bodyBytes(req)
+		if err != nil {
+			return err
+		}
+
+		payload = hashHex(body)
+	}
+
+	// the headers of a previous attempt
+	req.Header.Del("Authorization")
+
+	req.Header.Set("X-Amz-Date", now.Format(sigV4TimeFormat))
+
+	// s3 requires it, other services do not sign it
+	if s.Service == "s3" || s.UnsignedPayload {
+		req.Header.Set("X-Amz-Content-Sha256", payload)
+	}
+
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	req.URL.RawQuery = sigV4Query(req.URL.Query())
+
+	names, canonicalHeaders := sigV4Headers(req)
+	signedHeaders := strings.Join(names, ";")
+
+	scope := s.scope(now)
+	signature := s.signature(now, s.canonicalRequest(req, canonicalHeaders, signedHeaders, payload))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		SigV4Algorithm, s.AccessKey, scope, signedHeaders, signature))
+
+	return
+}
+
+// Presign returns the url of req signed in its query for expires
+// only the host header is signed, the payload is unsigned for s3
+func (s *SigV4) Presign(req *http.Request, expires time.Duration) (string, error) {
+	return s.presign(req, expires, time.Now())
+}
+
+func (s *SigV4) presign(req *http.Request, expires time.Duration, now time.Time) (presigned string, err error) {
+	now = now.UTC()
+
+	payload := UnsignedPayload
+
+	if s.Service != "s3" {
+		body, err := bodyBytes(req)
+		if err != nil {
+			return "", err
+		}
+
+		payload = hashHex(body)
+	}
+
+	query := req.URL.Query()
+
+	query.Set("X-Amz-Algorithm", SigV4Algorithm)
+	query.Set("X-Amz-Credential", s.AccessKey+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format(sigV4TimeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	if s.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	u := *req.URL
+	u.RawQuery = sigV4Query(query)
+
+	signed := req.Clone(req.Context())
+	signed.URL = &u
+
+	canonicalHeaders := "host:" + sigV4Host(signed) + "\n"
+	signature := s.signature(now, s.canonicalRequest(signed, canonicalHeaders, "host", payload))
+
+	u.RawQuery += "&X-Amz-Signature=" + signature
+
+	debug("PRESIGN", u.Host)
+
+	return u.String(), nil
+}
+
+// canonicalRequest returns the canonical request of req
+func (s *SigV4) canonicalRequest(req *http.Request, canonicalHeaders, signedHeaders, payload string) string {
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	// s3 encodes the path once, the other services twice
+	path = sigV4Escape(path, true)
+	if s.Service != "s3" {
+		path = sigV4Escape(path, true)
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payload,
+	}, "\n")
+}
+
+// scope returns the credential scope at now
+func (s *SigV4) scope(now time.Time) string {
+	return strings.Join([]string{now.Format(sigV4DateFormat), s.Region, s.Service, "aws4_request"}, "/")
+}
+
+// signature returns the hex signature of canonicalRequest at now
+func (s *SigV4) signature(now time.Time, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		SigV4Algorithm,
+		now.Format(sigV4TimeFormat),
+		s.scope(now),
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), now.Format(sigV4DateFormat))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// Presign returns the url of opt signed by its *SigV4 auth, Option.Auth or #SetAuth, for expires
+// the request is built as #Request would, but not sent
+func (c *Client) Presign(opt *Option, expires time.Duration) (presigned string, err error) {
+	opt, err = c.defaults.merge(opt)
+	if err != nil {
+		return
+	}
+
+	ctx := c.withAuth(context.Background(), opt)
+
+	signer, _ := ctx.Value(authKey{}).(*SigV4)
+	if signer == nil {
+		return "", ErrNoSigV4
+	}
+
+	req, err := newRequest(ctx, opt)
+	if err != nil {
+		return
+	}
+
+	return signer.Presign(req, expires)
+}
+
+// sigV4Headers returns the sorted signed header names of req and its canonical headers
+func sigV4Headers(req *http.Request) (names []string, canonical string) {
+	values := map[string]string{
+		"host": sigV4Host(req),
+	}
+
+	for key, vals := range req.Header {
+		name := strings.ToLower(key)
+		if sigV4SkipHeaders[name] {
+			continue
+		}
+
+		trimmed := make([]string, len(vals))
+		for i, v := range vals {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+
+		values[name] = strings.Join(trimmed, ",")
+	}
+
+	for name := range values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + values[name] + "\n")
+	}
+
+	return names, b.String()
+}
+
+// sigV4Host returns the host header req is sent with
+func sigV4Host(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+
+	return req.URL.Host
+}
+
+// sigV4Query returns the canonical query, sorted by key then value and %20 encoded
+func sigV4Query(query url.Values) string {
+	var pairs [][2]string
+
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, [2]string{sigV4Escape(key, false), sigV4Escape(value, false)})
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+
+		return pairs[i][1] < pairs[j][1]
+	})
+
+	encoded := make([]string, len(pairs))
+	for i, pair := range pairs {
+		encoded[i] = pair[0] + "=" + pair[1]
+	}
+
+	return strings.Join(encoded, "&")
+}
+
+// sigV4Escape percent-encodes s but the unreserved characters, and "/" in a path
+func sigV4Escape(s string, path bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', path && c == '/':
+			b.WriteByte(c)
+
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
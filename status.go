@@ -0,0 +1,87 @@
+package request
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// StatusRange is an inclusive range of status codes
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// status ranges
+var (
+	StatusSuccess = StatusRange{200, 299}
+	StatusAny     = StatusRange{100, 999}
+)
+
+// Contains returns true if code is in r
+func (r StatusRange) Contains(code int) bool {
+	return code >= r.Min && code <= r.Max
+}
+
+// StatusError is returned for a status code out of the accepted ranges
+// see #SetStatusError and Option.AcceptStatus
+type StatusError struct {
+	Method     string
+	URL        string // after redirects
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       string // beginning of the body, up to MaxSnippetSize bytes
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request: %s %s: %s", e.Method, e.URL, e.Status)
+}
+
+// SetStatusError makes the client requests and streams return a *StatusError
+// for the status codes out of accept, default: StatusSuccess
+// Option.AcceptStatus overrides it, StatusAny turns it off
+func (c *Client) SetStatusError(accept ...StatusRange) {
+	debug(accept)
+
+	if len(accept) == 0 {
+		accept = []StatusRange{StatusSuccess}
+	}
+
+	c.acceptStatus = accept
+}
+
+// checkStatus returns a *StatusError if res status code is not accepted
+// by opt or the client
+func (c *Client) checkStatus(res *http.Response, data []byte, opt *Option) error {
+	accept := c.acceptStatus
+	if opt.AcceptStatus != nil {
+		accept = opt.AcceptStatus
+	}
+
+	// off
+	if accept == nil {
+		return nil
+	}
+
+	for _, r := range accept {
+		if r.Contains(res.StatusCode) {
+			return nil
+		}
+	}
+
+	debug("ERR(status)", res.StatusCode)
+
+	body := data
+	if len(body) > MaxSnippetSize {
+		body = body[:MaxSnippetSize]
+	}
+
+	return &StatusError{
+		Method:     res.Request.Method,
+		URL:        res.Request.URL.String(),
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Header:     res.Header,
+		Body:       string(body),
+	}
+}
@@ -0,0 +1,169 @@
+package request
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStatusErrorOff(t *testing.T) {
+	ts := newDecodeServer()
+	defer ts.Close()
+
+	client := New()
+
+	_, res, err := client.Request(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"status": []string{"500"},
+		},
+	})
+	if err != nil || res.StatusCode != 500 {
+		t.Error(err)
+		return
+	}
+}
+
+func TestSetStatusError(t *testing.T) {
+	ts := newDecodeServer()
+	defer ts.Close()
+
+	client := New()
+	client.SetStatusError()
+
+	_, _, err := client.Request(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"status": []string{"201"},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	body := strings.Repeat("x", MaxSnippetSize+1)
+
+	res, err := client.Do(&Option{
+		URL:    ts.URL,
+		Method: "DELETE",
+		Query: &Data{
+			"status": []string{"404"},
+			"type":   []string{"text/plain"},
+			"body":   []string{body},
+		},
+	})
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Error(err)
+		return
+	}
+
+	if statusErr.Method != "DELETE" || statusErr.StatusCode != 404 || statusErr.Status != "404 Not Found" {
+		t.Error(statusErr)
+		return
+	}
+
+	if !strings.HasPrefix(statusErr.URL, ts.URL) || statusErr.Header.Get("Content-Type") != "text/plain" {
+		t.Error(statusErr.URL, statusErr.Header)
+		return
+	}
+
+	if statusErr.Body != body[:MaxSnippetSize] {
+		t.Error(len(statusErr.Body))
+		return
+	}
+
+	// the response is still returned
+	if res == nil || res.String() != body {
+		t.Error(res)
+		return
+	}
+}
+
+func TestAcceptStatus(t *testing.T) {
+	ts := newDecodeServer()
+	defer ts.Close()
+
+	client := New()
+	client.SetStatusError(StatusSuccess, StatusRange{404, 404})
+
+	_, _, err := client.Request(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"status": []string{"404"},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the option wins
+	_, _, err = client.Request(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"status": []string{"500"},
+		},
+		AcceptStatus: []StatusRange{StatusAny},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var statusErr *StatusError
+
+	_, _, err = client.Request(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"status": []string{"202"},
+		},
+		AcceptStatus: []StatusRange{{200, 200}},
+	})
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusAccepted {
+		t.Error(err)
+		return
+	}
+
+	// per option only
+	_, _, err = New().Request(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"status": []string{"500"},
+		},
+		AcceptStatus: []StatusRange{StatusSuccess},
+	})
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 500 {
+		t.Error(err)
+		return
+	}
+}
+
+func TestStatusErrorResult(t *testing.T) {
+	ts := newDecodeServer()
+	defer ts.Close()
+
+	client := New()
+	client.SetStatusError()
+
+	var apiErr decodeAPIError
+
+	_, _, err := client.Request(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"status": []string{"400"},
+			"type":   []string{"application/json"},
+			"body":   []string{`{"code":"invalid"}`},
+		},
+		ErrorResult: &apiErr,
+	})
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || apiErr.Code != "invalid" {
+		t.Error(err, apiErr)
+		return
+	}
+}
@@ -0,0 +1,91 @@
+package request
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+const (
+	// MaxDrainSize is the max number of unread bytes discarded on stream Close
+	// so the connection can be reused, bigger leftovers close the connection
+	MaxDrainSize = 256 << 10
+)
+
+// Stream sends http request and returns the live response body
+// the body is not buffered, the caller must Close it
+// res.Body is the same reader as body
+// a *StatusError is returned as with #Client.Request, the body is then closed
+func (c *Client) Stream(opt *Option) (body io.ReadCloser, res *http.Response, err error) {
+	return c.StreamContext(opt.Context, opt)
+}
+
+// StreamContext sends http request with ctx and returns the live response body
+// ctx must stay alive while reading the body
+func (c *Client) StreamContext(ctx context.Context, opt *Option) (body io.ReadCloser, res *http.Response, err error) {
+	ctx = optContext(ctx, opt)
+
+	res, err = c.do(ctx, opt)
+	if err != nil {
+		return
+	}
+
+	body = &drainBody{ctx: ctx, rc: res.Body}
+	res.Body = body
+
+	res, _, err = c.runAfter(res, nil)
+	if err != nil {
+		body.Close()
+		return nil, res, err
+	}
+
+	err = c.checkStatus(res, nil, opt)
+	if err != nil {
+		snippet, _ := ioutil.ReadAll(io.LimitReader(res.Body, MaxSnippetSize))
+		res.Body.Close()
+
+		if statusErr, ok := err.(*StatusError); ok {
+			statusErr.Body = string(snippet)
+		}
+
+		return nil, res, err
+	}
+
+	// a hook may replace the response
+	body = res.Body
+	return
+}
+
+// drainBody discards the unread body on Close
+// so golang http can return the connection to the pool
+type drainBody struct {
+	ctx  context.Context
+	rc   io.ReadCloser
+	once sync.Once
+	err  error
+}
+
+func (b *drainBody) Read(p []byte) (n int, err error) {
+	n, err = b.rc.Read(p)
+	if err != nil && err != io.EOF {
+		err = ctxError(b.ctx, err)
+	}
+	return
+}
+
+// Close drains up to MaxDrainSize bytes then closes the body
+// it is safe to call Close more than once
+func (b *drainBody) Close() error {
+	b.once.Do(func() {
+		_, err := io.CopyN(ioutil.Discard, b.rc, MaxDrainSize)
+		if err != nil && err != io.EOF {
+			debug("ERR(drain)", err)
+		}
+
+		b.err = b.rc.Close()
+	})
+
+	return b.err
+}
@@ -0,0 +1,181 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"testing"
+)
+
+const streamSize = 1 << 20
+
+func newStreamServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunk := strings.Repeat("a", 1024)
+
+		for i := 0; i < streamSize/len(chunk); i++ {
+			w.Write([]byte(chunk))
+		}
+	}))
+}
+
+func TestStream(t *testing.T) {
+	ts := newStreamServer()
+	defer ts.Close()
+
+	client := New()
+
+	body, res, err := client.Stream(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer body.Close()
+
+	if res.StatusCode != 200 {
+		t.Error()
+		return
+	}
+
+	n, err := io.Copy(ioutil.Discard, body)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if n != streamSize {
+		t.Error(n)
+		return
+	}
+}
+
+func TestStreamClose(t *testing.T) {
+	ts := newStreamServer()
+	defer ts.Close()
+
+	client := New()
+
+	body, res, err := client.Stream(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// res.Body is the same stream
+	if res.Body != body {
+		t.Error()
+		return
+	}
+
+	buf := make([]byte, 10)
+	if _, err = io.ReadFull(body, buf); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if body.Close() != nil {
+		t.Error()
+		return
+	}
+
+	// closing twice is safe
+	if res.Body.Close() != nil {
+		t.Error()
+		return
+	}
+}
+
+func TestStreamReuse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", MaxDrainSize/2)))
+	}))
+	defer ts.Close()
+
+	client := New()
+
+	// first request reads nothing
+	body, _, err := client.Stream(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	body.Close()
+
+	// second request must reuse the drained connection
+	var reused bool
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused = info.Reused
+		},
+	})
+
+	body, _, err = client.StreamContext(ctx, &Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	body.Close()
+
+	if !reused {
+		t.Error()
+		return
+	}
+}
+
+func TestStreamStatusError(t *testing.T) {
+	ts := newDecodeServer()
+	defer ts.Close()
+
+	client := New()
+	client.SetStatusError()
+
+	data := strings.Repeat("x", MaxSnippetSize+1)
+
+	body, res, err := client.Stream(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"status": []string{"500"},
+			"type":   []string{"text/plain"},
+			"body":   []string{data},
+		},
+	})
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || body != nil {
+		t.Error(err)
+		return
+	}
+
+	if statusErr.StatusCode != 500 || statusErr.Body != data[:MaxSnippetSize] || res.StatusCode != 500 {
+		t.Error(statusErr.StatusCode, len(statusErr.Body))
+		return
+	}
+
+	// the option wins
+	body, _, err = client.Stream(&Option{
+		URL: ts.URL,
+		Query: &Data{
+			"status": []string{"500"},
+		},
+		AcceptStatus: []StatusRange{StatusAny},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	body.Close()
+}
@@ -0,0 +1,184 @@
+package request
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+var (
+	// ErrNoCertificate is returned for a CA PEM without any certificate
+	ErrNoCertificate = errors.New("request: no PEM certificate")
+
+	// ErrPinMismatch is returned when no certificate of the server matches #TLSOption.Pins
+	ErrPinMismatch = errors.New("request: certificate pin mismatch")
+)
+
+// pinPrefix is the optional prefix of the pins, as in HPKP
+const pinPrefix = "sha256/"
+
+// TLSOption holds the #Client TLS settings
+type TLSOption struct {
+	CAFiles []string // PEM files of root CAs, added to the system roots
+	CAPEM   []byte   // PEM root CAs, added to the system roots
+
+	ClientCerts []*ClientCert // mTLS
+
+	MinVersion uint16 // default: tls.VersionTLS12
+
+	// base64 sha256 of the subject public key info, see #SPKIPin
+	// one certificate of the verified chain must match one of them
+	Pins []string
+
+	InsecureSkipVerify bool   // for development only, pins are still checked against the server certificate only
+	ServerName         string // SNI and verified host name, default: the request host
+}
+
+// ClientCert is a client certificate and its private key, as PEM files or PEM
+type ClientCert struct {
+	CertFile string
+	KeyFile  string
+
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// SPKIPin returns the pin of cert for #TLSOption.Pins
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// SetTLS sets client TLS settings, opt can be nil
+// the proxy and connection settings are kept
+func (c *Client) SetTLS(opt *TLSOption) (err error) {
+	debug()
+
+	if opt == nil {
+		opt = &TLSOption{}
+	}
+
+	config, err := newTLSConfig(opt)
+	if err != nil {
+		return
+	}
+
+	// keep the protocols negotiated by the transport, like h2
+	if c.transport.TLSClientConfig != nil {
+		config.NextProtos = c.transport.TLSClientConfig.NextProtos
+	}
+
+	c.transport.TLSClientConfig = config
+
+	// new connections get the new settings
+	c.transport.CloseIdleConnections()
+	return
+}
+
+func newTLSConfig(opt *TLSOption) (config *tls.Config, err error) {
+	config = &tls.Config{
+		MinVersion:         opt.MinVersion,
+		InsecureSkipVerify: opt.InsecureSkipVerify,
+		ServerName:         opt.ServerName,
+	}
+
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+
+	if len(opt.CAFiles) > 0 || len(opt.CAPEM) > 0 {
+		config.RootCAs, err = loadRootCAs(opt.CAFiles, opt.CAPEM)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, clientCert := range opt.ClientCerts {
+		cert, err := clientCert.load()
+		if err != nil {
+			debug("ERR(load)", err)
+			return nil, err
+		}
+
+		config.Certificates = append(config.Certificates, cert)
+	}
+
+	if len(opt.Pins) > 0 {
+		pins := map[string]bool{}
+
+		for _, pin := range opt.Pins {
+			pins[strings.TrimPrefix(pin, pinPrefix)] = true
+		}
+
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyPins(state, pins, opt.InsecureSkipVerify)
+		}
+	}
+
+	return
+}
+
+// loadRootCAs returns the system roots with the certificates of files and pem
+func loadRootCAs(files []string, pem []byte) (pool *x509.CertPool, err error) {
+	pool, err = x509.SystemCertPool()
+	if err != nil {
+		debug("ERR(SystemCertPool)", err)
+		pool = x509.NewCertPool()
+	}
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			debug("ERR(ReadFile)", err)
+			return nil, err
+		}
+
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%w: %s", ErrNoCertificate, file)
+		}
+	}
+
+	if len(pem) > 0 && !pool.AppendCertsFromPEM(pem) {
+		return nil, ErrNoCertificate
+	}
+
+	err = nil
+	return
+}
+
+func (c *ClientCert) load() (cert tls.Certificate, err error) {
+	if c.CertFile != "" || c.KeyFile != "" {
+		return tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	}
+
+	return tls.X509KeyPair(c.CertPEM, c.KeyPEM)
+}
+
+// verifyPins checks that one certificate of the verified chains is pinned,
+// or the server certificate without verification
+// the other certificates sent by the server are not trusted
+func verifyPins(state tls.ConnectionState, pins map[string]bool, insecure bool) error {
+	var certs []*x509.Certificate
+
+	if insecure && len(state.PeerCertificates) > 0 {
+		certs = state.PeerCertificates[:1]
+	}
+
+	for _, chain := range state.VerifiedChains {
+		certs = append(certs, chain...)
+	}
+
+	for _, cert := range certs {
+		if pins[SPKIPin(cert)] {
+			return nil
+		}
+	}
+
+	debug("ERR(pin)", state.ServerName)
+	return fmt.Errorf("%w: %s", ErrPinMismatch, state.ServerName)
+}
@@ -0,0 +1,419 @@
+package request
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTLSServer returns a started TLS server that answers the SNI it got
+func newTLSServer(config *tls.Config) *httptest.Server {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.ServerName))
+	}))
+	ts.TLS = config
+	ts.StartTLS()
+
+	return ts
+}
+
+// certPEM returns cert as PEM
+func certPEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// newClientCert returns a self-signed client certificate and its key as PEM
+func newClientCert() (cert *x509.Certificate, certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return
+	}
+
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		return
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return
+}
+
+// newServerCert returns a certificate of 127.0.0.1 signed by parent, self-signed if parent is nil
+func newServerCert(isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (cert *x509.Certificate, key *ecdsa.PrivateKey, err error) {
+	key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "server"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return
+	}
+
+	cert, err = x509.ParseCertificate(der)
+	return
+}
+
+func TestSetTLSCAFile(t *testing.T) {
+	ts := newTLSServer(nil)
+	defer ts.Close()
+
+	client := New()
+
+	// unknown authority
+	_, _, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err == nil {
+		t.Error()
+		return
+	}
+
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	ioutil.WriteFile(file, certPEM(ts.Certificate()), 0600)
+
+	err = client.SetTLS(&TLSOption{
+		CAFiles: []string{file},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, res, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.TLS == nil || res.TLS.Version < tls.VersionTLS12 {
+		t.Error(res.TLS)
+		return
+	}
+
+	// no certificate
+	err = client.SetTLS(&TLSOption{
+		CAPEM: []byte("nothing"),
+	})
+	if !errors.Is(err, ErrNoCertificate) {
+		t.Error(err)
+		return
+	}
+}
+
+func TestSetTLSServerName(t *testing.T) {
+	ts := newTLSServer(nil)
+	defer ts.Close()
+
+	client := New()
+
+	// the test certificate is valid for example.com
+	err := client.SetTLS(&TLSOption{
+		CAPEM:      certPEM(ts.Certificate()),
+		ServerName: "example.com",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	data, _, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if string(data) != "example.com" {
+		t.Error(string(data))
+		return
+	}
+
+	client.SetTLS(&TLSOption{
+		CAPEM:      certPEM(ts.Certificate()),
+		ServerName: "example.org",
+	})
+
+	_, _, err = client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err == nil {
+		t.Error()
+		return
+	}
+}
+
+func TestSetTLSClientCert(t *testing.T) {
+	cert, certPEM, keyPEM, err := newClientCert()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+
+	ts := newTLSServer(&tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	})
+	defer ts.Close()
+
+	client := New()
+
+	// no client certificate
+	client.SetTLS(&TLSOption{
+		InsecureSkipVerify: true,
+	})
+
+	_, _, err = client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err == nil {
+		t.Error()
+		return
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(certFile, certPEM, 0600)
+	ioutil.WriteFile(keyFile, keyPEM, 0600)
+
+	clientCerts := [][]*ClientCert{
+		{{CertPEM: certPEM, KeyPEM: keyPEM}},
+		{{CertFile: certFile, KeyFile: keyFile}},
+	}
+
+	for _, clientCert := range clientCerts {
+		err = client.SetTLS(&TLSOption{
+			InsecureSkipVerify: true,
+			ClientCerts:        clientCert,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		_, res, err := client.Request(&Option{
+			URL: ts.URL,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if res.StatusCode != 200 {
+			t.Error(res.StatusCode)
+			return
+		}
+	}
+}
+
+func TestSetTLSPins(t *testing.T) {
+	ts := newTLSServer(nil)
+	defer ts.Close()
+
+	client := New()
+
+	err := client.SetTLS(&TLSOption{
+		CAPEM: certPEM(ts.Certificate()),
+		Pins:  []string{"sha256/" + SPKIPin(ts.Certificate())},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, _, err = client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// pins are checked without verification too
+	client.SetTLS(&TLSOption{
+		InsecureSkipVerify: true,
+		Pins:               []string{strings.Repeat("A", 43) + "="},
+	})
+
+	_, _, err = client.Request(&Option{
+		URL: ts.URL,
+	})
+	if !errors.Is(err, ErrPinMismatch) {
+		t.Error(err)
+		return
+	}
+}
+
+func TestSetTLSPinsAppended(t *testing.T) {
+	ca, caKey, err := newServerCert(true, nil, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	leaf, leafKey, err := newServerCert(false, ca, caKey)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	pinned, _, err := newServerCert(false, nil, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// a valid unpinned leaf followed by the public pinned certificate
+	ts := newTLSServer(&tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{leaf.Raw, pinned.Raw},
+			PrivateKey:  leafKey,
+		}},
+	})
+	defer ts.Close()
+
+	for _, opt := range []*TLSOption{
+		{CAPEM: certPEM(ca), Pins: []string{SPKIPin(pinned)}},
+		{InsecureSkipVerify: true, Pins: []string{SPKIPin(pinned)}},
+	} {
+		client := New()
+		client.SetTLS(opt)
+
+		_, _, err = client.Request(&Option{
+			URL: ts.URL,
+		})
+		if !errors.Is(err, ErrPinMismatch) {
+			t.Error(opt.InsecureSkipVerify, err)
+			return
+		}
+	}
+
+	// the CA of the verified chain
+	client := New()
+	client.SetTLS(&TLSOption{
+		CAPEM: certPEM(ca),
+		Pins:  []string{SPKIPin(ca)},
+	})
+
+	_, _, err = client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+}
+
+func TestSetTLSKeepsTransport(t *testing.T) {
+	ts := newTLSServer(&tls.Config{
+		MaxVersion: tls.VersionTLS12,
+	})
+	defer ts.Close()
+
+	proxy := newConnectProxy()
+	defer proxy.Close()
+
+	client := New()
+	client.SetTransport(&TransportOption{
+		MaxConnsPerHost: 3,
+	})
+	client.SetProxyWithOption(proxy.URL, &ProxyOption{
+		Username: "user",
+		Password: "pass",
+		ConnectHeader: &Header{
+			"X-Tunnel": "one",
+		},
+	})
+
+	err := client.SetTLS(&TLSOption{
+		CAPEM: certPEM(ts.Certificate()),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if client.transport.MaxConnsPerHost != 3 {
+		t.Error(client.transport.MaxConnsPerHost)
+		return
+	}
+
+	// through the CONNECT proxy
+	_, _, err = client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	client.SetTLS(&TLSOption{
+		CAPEM:      certPEM(ts.Certificate()),
+		MinVersion: tls.VersionTLS13,
+	})
+
+	_, _, err = client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err == nil {
+		t.Error()
+		return
+	}
+}
@@ -0,0 +1,129 @@
+package request
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// the defaults of http.DefaultTransport
+const (
+	// DefaultDialTimeout is the default connect timeout
+	DefaultDialTimeout = 30 * time.Second
+
+	// DefaultKeepAlive is the default interval of TCP keep-alive probes
+	DefaultKeepAlive = 30 * time.Second
+
+	// DefaultTLSHandshakeTimeout is the default TLS handshake timeout
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+
+	// DefaultMaxIdleConns is the default maximum number of idle connections across all hosts
+	DefaultMaxIdleConns = 100
+
+	// DefaultIdleConnTimeout is the default time an idle connection is kept
+	DefaultIdleConnTimeout = 90 * time.Second
+)
+
+// TransportOption holds the #Client connection settings
+// zero values are the defaults
+type TransportOption struct {
+	DialTimeout           time.Duration // default: DefaultDialTimeout
+	KeepAlive             time.Duration // default: DefaultKeepAlive, negative disables the probes
+	TLSHandshakeTimeout   time.Duration // default: DefaultTLSHandshakeTimeout
+	ResponseHeaderTimeout time.Duration // default: no timeout
+	MaxIdleConns          int           // default: DefaultMaxIdleConns
+	MaxIdleConnsPerHost   int           // default: http.DefaultMaxIdleConnsPerHost
+	MaxConnsPerHost       int           // default: no limit
+	IdleConnTimeout       time.Duration // default: DefaultIdleConnTimeout
+	DisableKeepAlives     bool          // one connection per request
+}
+
+// newTransport returns a clone of http.DefaultTransport and the dialer it connects with
+func newTransport() (transport *http.Transport, dialer *net.Dialer) {
+	dialer = &net.Dialer{
+		Timeout:   DefaultDialTimeout,
+		KeepAlive: DefaultKeepAlive,
+	}
+
+	transport = http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+
+	return
+}
+
+// SetTransport sets client connection settings, opt can be nil
+// the proxy and TLS settings are kept
+func (c *Client) SetTransport(opt *TransportOption) {
+	debug()
+
+	if opt == nil {
+		opt = &TransportOption{}
+	}
+
+	c.dialer.Timeout = opt.DialTimeout
+	if c.dialer.Timeout == 0 {
+		c.dialer.Timeout = DefaultDialTimeout
+	}
+
+	c.dialer.KeepAlive = opt.KeepAlive
+	if c.dialer.KeepAlive == 0 {
+		c.dialer.KeepAlive = DefaultKeepAlive
+	}
+
+	c.transport.TLSHandshakeTimeout = opt.TLSHandshakeTimeout
+	if c.transport.TLSHandshakeTimeout == 0 {
+		c.transport.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+	}
+
+	c.transport.MaxIdleConns = opt.MaxIdleConns
+	if c.transport.MaxIdleConns == 0 {
+		c.transport.MaxIdleConns = DefaultMaxIdleConns
+	}
+
+	c.transport.IdleConnTimeout = opt.IdleConnTimeout
+	if c.transport.IdleConnTimeout == 0 {
+		c.transport.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+
+	c.transport.ResponseHeaderTimeout = opt.ResponseHeaderTimeout
+	c.transport.MaxIdleConnsPerHost = opt.MaxIdleConnsPerHost
+	c.transport.MaxConnsPerHost = opt.MaxConnsPerHost
+	c.transport.DisableKeepAlives = opt.DisableKeepAlives
+
+	// new connections get the new settings
+	c.transport.CloseIdleConnections()
+}
+
+// ClearProxy connects the client directly, without any proxy
+func (c *Client) ClearProxy() {
+	debug()
+
+	c.resetProxy()
+	c.transport.CloseIdleConnections()
+}
+
+// SetProxyFromEnvironment sets client proxy from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables (or their lowercase), read when called
+func (c *Client) SetProxyFromEnvironment() {
+	debug()
+
+	proxyFunc := httpproxy.FromEnvironment().ProxyFunc()
+
+	c.resetProxy()
+	c.transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+	c.transport.CloseIdleConnections()
+}
+
+// resetProxy removes the proxy settings of the client transport
+func (c *Client) resetProxy() {
+	c.transport.Proxy = nil
+	c.transport.ProxyConnectHeader = nil
+	c.transport.DialContext = c.dialer.DialContext
+
+	c.httpClient.Transport = c.transport
+}
@@ -0,0 +1,158 @@
+package request
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSetTransport(t *testing.T) {
+	client := New()
+
+	client.SetTransport(&TransportOption{
+		DialTimeout:         time.Second,
+		MaxIdleConnsPerHost: 7,
+		DisableKeepAlives:   true,
+	})
+
+	if client.dialer.Timeout != time.Second || client.dialer.KeepAlive != DefaultKeepAlive {
+		t.Error(client.dialer)
+		return
+	}
+
+	transport := client.transport
+	if transport.MaxIdleConnsPerHost != 7 || !transport.DisableKeepAlives || transport.MaxIdleConns != DefaultMaxIdleConns {
+		t.Error(transport)
+		return
+	}
+
+	// defaults
+	client.SetTransport(nil)
+
+	if client.dialer.Timeout != DefaultDialTimeout || transport.MaxIdleConnsPerHost != 0 || transport.DisableKeepAlives {
+		t.Error(client.dialer, transport)
+		return
+	}
+}
+
+func TestSetProxyKeepsTransport(t *testing.T) {
+	proxy := newTestProxy("proxy", 200)
+	defer proxy.Close()
+
+	client := New()
+	transport := client.transport
+
+	tlsConfig := &tls.Config{ServerName: "example.com"}
+	transport.TLSClientConfig = tlsConfig
+
+	client.SetTransport(&TransportOption{
+		MaxConnsPerHost: 3,
+	})
+
+	err := client.SetProxy(proxy.URL)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if client.httpClient.Transport != transport || client.transport != transport {
+		t.Error(client.httpClient.Transport)
+		return
+	}
+
+	if transport.TLSClientConfig != tlsConfig || transport.MaxConnsPerHost != 3 || transport.TLSHandshakeTimeout != DefaultTLSHandshakeTimeout {
+		t.Error(transport)
+		return
+	}
+
+	data, _, err := client.Request(&Option{
+		URL: "http://example.com/",
+	})
+	if err != nil || string(data) != "proxy" {
+		t.Error(err, string(data))
+		return
+	}
+
+	// the pool uses the client transport too
+	pool, _ := NewProxyPool([]string{proxy.URL}, nil)
+	client.SetProxyPool(pool)
+
+	if client.httpClient.Transport.(*poolTransport).transport != transport || transport.TLSClientConfig != tlsConfig {
+		t.Error(client.httpClient.Transport)
+		return
+	}
+
+	data, _, err = client.Request(&Option{
+		URL: "http://example.com/",
+	})
+	if err != nil || string(data) != "proxy" {
+		t.Error(err, string(data))
+		return
+	}
+}
+
+func TestClearProxy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer ts.Close()
+
+	proxy := newTestProxy("proxy", 200)
+	defer proxy.Close()
+
+	client := New()
+	client.SetProxy(proxy.URL)
+
+	data, _, _ := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if string(data) != "proxy" {
+		t.Error(string(data))
+		return
+	}
+
+	client.ClearProxy()
+
+	data, _, err := client.Request(&Option{
+		URL: ts.URL,
+	})
+	if err != nil || string(data) != "direct" {
+		t.Error(err, string(data))
+		return
+	}
+
+	if client.httpClient.Transport != client.transport || client.transport.Proxy != nil {
+		t.Error(client.httpClient.Transport)
+		return
+	}
+}
+
+func TestSetProxyFromEnvironment(t *testing.T) {
+	proxy := newTestProxy("proxy", 200)
+	defer proxy.Close()
+
+	t.Setenv("HTTP_PROXY", proxy.URL)
+	t.Setenv("NO_PROXY", "example.org")
+
+	client := New()
+	client.ClearProxy()
+	client.SetProxyFromEnvironment()
+
+	data, _, err := client.Request(&Option{
+		URL: "http://example.com/",
+	})
+	if err != nil || string(data) != "proxy" {
+		t.Error(err, string(data))
+		return
+	}
+
+	req, _ := http.NewRequest("GET", "http://example.org/", nil)
+
+	proxyURL, err := client.transport.Proxy(req)
+	if err != nil || proxyURL != nil {
+		t.Error(err, proxyURL)
+		return
+	}
+}